@@ -0,0 +1,141 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nanolog
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// A trailer marks the clean end of a log, so a reader can tell a complete log
+// from one cut short and check it wasn't damaged. It is written by Close when
+// WithTrailer is on, formatted as follows:
+//
+//  - type:    1 byte - ETTrailer (6)
+//  - flags:   1 byte - TrailerHasChecksum if the checksum is set
+//  - lines:   8 bytes - little endian uint64 number of log line records in the log
+//  - entries: 8 bytes - little endian uint64 number of log entry records in the log
+//  - size:    8 bytes - little endian uint64 number of bytes in the log before the trailer
+//  - sum:     4 bytes - little endian uint32 CRC-32 (IEEE) of those bytes, or 0
+//
+// Like the index, the trailer only matches the log if everything the LogWriter
+// wrote went to a single writer.
+
+// TrailerHasChecksum is the flag set in a trailer that has a checksum
+const TrailerHasChecksum = 1
+
+// TrailerLen is the size of a trailer record
+const TrailerLen = 30
+
+// ErrClosed is returned by Log, Flush and SetWriter after Close
+var ErrClosed = errors.New("LogWriter is closed")
+
+// WithTrailer makes Close write a trailer record with the number of log lines
+// and entries and a checksum of the log. The checksum is only kept if the
+// option is given before anything is written out, i.e. to New or to Configure
+// before the first Log or SetWriter; otherwise the trailer has the counts alone.
+// A log written to a ring never has a checksum, see WithRing.
+func WithTrailer(enabled bool) Option {
+	return func(lw *logWriter) {
+		lw.trailer = enabled
+
+		if !enabled {
+			lw.sum = nil
+			return
+		}
+
+		if lw.sum == nil && lw.ring == nil {
+			lw.sum = crc32.NewIEEE()
+			if !lw.rewriteHeader() {
+				// too late to checksum what was already written
+				lw.sum = nil
+			}
+		}
+	}
+}
+
+// Close calls LogWriter.Close on the default log writer.
+func Close() error {
+	return defaultLogWriter.Close()
+}
+
+// Close flushes the log and closes the writers given to SetWriter and WithIndex
+// if they are io.Closers, except for os.Stdout and os.Stderr. With WithSync the
+// log is synced before it is closed. With WithTrailer the trailer is written
+// first. After Close, Log, Flush and SetWriter return ErrClosed and so does
+// Close itself.
+func (lw *logWriter) Close() error {
+	lw.writeLock.Lock()
+	defer lw.writeLock.Unlock()
+
+	if lw.closed {
+		return ErrClosed
+	}
+	lw.closed = true
+
+	stopRunning(&lw.flushStop)
+	stopRunning(&lw.syncStop)
+
+	if lw.trailer {
+		lw.writeTrailer()
+	}
+
+	var err error
+	if lw.syncing() {
+		err = lw.sync()
+	} else {
+		err = lw.flush()
+	}
+
+	for _, w := range []io.Writer{lw.out, lw.indexOut} {
+		if w == os.Stdout || w == os.Stderr {
+			continue
+		}
+		if c, ok := w.(io.Closer); ok {
+			if cerr := c.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}
+	}
+
+	// nothing was ever written out if SetWriter wasn't called
+	lw.removeSpill()
+
+	return err
+}
+
+// writeTrailer writes the trailer record. The write lock must be held.
+func (lw *logWriter) writeTrailer() {
+	if lw.ring != nil {
+		// the entries the trailer overwrites mustn't be counted in it
+		lw.reserveRing(TrailerLen)
+	}
+
+	var b [TrailerLen]byte
+	b[0] = byte(ETTrailer)
+	binary.LittleEndian.PutUint64(b[2:], lw.lines)
+	binary.LittleEndian.PutUint64(b[10:], lw.entries-lw.ringDropped)
+	binary.LittleEndian.PutUint64(b[18:], uint64(lw.offset-lw.ringDroppedBytes))
+
+	if lw.sum != nil {
+		b[1] = TrailerHasChecksum
+		binary.LittleEndian.PutUint32(b[26:], lw.sum.Sum32())
+	}
+
+	lw.write(b[:])
+}
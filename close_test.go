@@ -0,0 +1,126 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nanolog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+)
+
+type closeBuffer struct {
+	bytes.Buffer
+	closed int
+}
+
+func (c *closeBuffer) Close() error {
+	c.closed++
+	return nil
+}
+
+func TestClose(t *testing.T) {
+	out := &closeBuffer{}
+	lw := New()
+	lw.SetWriter(out)
+
+	h := lw.AddLogger("close %u8")
+	lw.Log(h, uint8(1))
+
+	if err := lw.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := append(logLineRecord(0, parseLogLine("close %u8")), byte(ETLogEntry), 0, 0, 0, 0, 1)
+	if !bytes.Equal(out.Bytes(), expected) {
+		t.Fatalf("Expected Close to flush the log.\nExpected: % X\nGot: % X", expected, out.Bytes())
+	}
+	if out.closed != 1 {
+		t.Fatalf("Expected the writer to be closed once but it was closed %d times", out.closed)
+	}
+
+	if err := lw.Log(h, uint8(2)); err != ErrClosed {
+		t.Fatalf("Expected ErrClosed from Log but got %v", err)
+	}
+	if err := lw.Flush(); err != ErrClosed {
+		t.Fatalf("Expected ErrClosed from Flush but got %v", err)
+	}
+	if err := lw.SetWriter(&bytes.Buffer{}); err != ErrClosed {
+		t.Fatalf("Expected ErrClosed from SetWriter but got %v", err)
+	}
+	if err := lw.Close(); err != ErrClosed {
+		t.Fatalf("Expected ErrClosed from a second Close but got %v", err)
+	}
+
+	lw.AddLogger("after close")
+	if out.Len() != len(expected) || out.closed != 1 {
+		t.Fatalf("Expected nothing to be written after Close")
+	}
+}
+
+func TestCloseTrailer(t *testing.T) {
+	trailer := func(b []byte) []byte {
+		return b[len(b)-TrailerLen:]
+	}
+
+	t.Run("Checksum", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		lw := New(WithTrailer(true))
+		h := lw.AddLogger("trailer %i")
+		lw.SetWriter(buf)
+		lw.Log(h, 1)
+		lw.Log(h, 2)
+		lw.Close()
+
+		b := buf.Bytes()
+		tr := trailer(b)
+		body := b[:len(b)-TrailerLen]
+
+		if EntryType(tr[0]) != ETTrailer || tr[1] != TrailerHasChecksum {
+			t.Fatalf("Expected a trailer with a checksum but got % X", tr)
+		}
+		if lines := binary.LittleEndian.Uint64(tr[2:]); lines != 1 {
+			t.Fatalf("Expected 1 log line but got %d", lines)
+		}
+		if entries := binary.LittleEndian.Uint64(tr[10:]); entries != 2 {
+			t.Fatalf("Expected 2 entries but got %d", entries)
+		}
+		if size := binary.LittleEndian.Uint64(tr[18:]); size != uint64(len(body)) {
+			t.Fatalf("Expected a size of %d but got %d", len(body), size)
+		}
+		if sum := binary.LittleEndian.Uint32(tr[26:]); sum != crc32.ChecksumIEEE(body) {
+			t.Fatalf("Expected checksum %08x but got %08x", crc32.ChecksumIEEE(body), sum)
+		}
+	})
+
+	t.Run("Late", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		lw := New()
+		lw.SetWriter(buf)
+		h := lw.AddLogger("late %i")
+		lw.Log(h, 1)
+
+		WithTrailer(true)(lw.(*logWriter))
+		lw.Close()
+
+		tr := trailer(buf.Bytes())
+		if EntryType(tr[0]) != ETTrailer || tr[1] != 0 {
+			t.Fatalf("Expected a trailer without a checksum but got % X", tr)
+		}
+		if entries := binary.LittleEndian.Uint64(tr[10:]); entries != 1 {
+			t.Fatalf("Expected 1 entry but got %d", entries)
+		}
+	})
+}
@@ -0,0 +1,288 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ScottMansfield/nanolog"
+	"github.com/ScottMansfield/nanolog/reader"
+)
+
+// inflater is implemented by both reader.Reader and reader.Merger
+type inflater interface {
+	Inflate() error
+	InflateJSON() error
+	InflateTemplate(tmpl *template.Template) error
+}
+
+// outputFlags are the flags that choose how entries are written out
+type outputFlags struct {
+	out      string
+	asJSON   bool
+	format   string
+	location bool
+	tmpl     *template.Template
+}
+
+// locationFormat is the text output with the source location of the log line
+const locationFormat = `{{if .Source}}{{.Source}}: {{end}}{{if .File}}{{base .File}}:{{.Line}}: {{end}}{{.Message}}`
+
+func (o *outputFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.out, "o", "", "Write the output to this file instead of the standard output")
+	fs.BoolVar(&o.asJSON, "json", false, "Output one JSON object per log entry (JSON Lines)")
+	fs.StringVar(&o.format, "format", "", "Output each log entry using this text/template")
+	fs.BoolVar(&o.location, "location", false, "Prefix each entry with the file and line that added its log line, if the log has them")
+}
+
+// check validates the output flags once they are parsed
+func (o *outputFlags) check() error {
+	if o.asJSON && o.format != "" {
+		return errors.New("-json and -format can't be used together")
+	}
+	if o.location && (o.asJSON || o.format != "") {
+		return errors.New("-location is only for the plain text output")
+	}
+
+	if o.location {
+		o.format = locationFormat
+	}
+
+	if o.format != "" {
+		tmpl, err := reader.NewTemplate(o.format)
+		if err != nil {
+			return err
+		}
+		o.tmpl = tmpl
+	}
+
+	return nil
+}
+
+func (o *outputFlags) inflate(in inflater) error {
+	switch {
+	case o.asJSON:
+		return in.InflateJSON()
+	case o.tmpl != nil:
+		return in.InflateTemplate(o.tmpl)
+	default:
+		return in.Inflate()
+	}
+}
+
+// filterFlags are the flags that choose which entries are written out
+type filterFlags struct {
+	handles string
+	match   string
+	level   string
+	where   stringsFlag
+}
+
+func (f *filterFlags) register(fs *flag.FlagSet, match bool) {
+	fs.StringVar(&f.handles, "handle", "", "Only output entries for these comma separated handle ids")
+	if match {
+		fs.StringVar(&f.match, "match", "", "Only output entries whose format string matches this regular expression")
+	}
+	fs.StringVar(&f.level, "level", "", "Only output entries for log lines at this level or above: debug, info, warn, error or fatal")
+	fs.Var(&f.where, "where", "Only output entries whose arguments satisfy this condition, e.g. \"arg2 > 500\". May be repeated")
+}
+
+// filter builds the reader filter from the flags, or returns nil when no
+// filtering was asked for
+func (f *filterFlags) filter() (*reader.Filter, error) {
+	if f.handles == "" && f.match == "" && f.level == "" && len(f.where) == 0 {
+		return nil, nil
+	}
+
+	filter := &reader.Filter{}
+
+	if f.handles != "" {
+		for _, h := range strings.Split(f.handles, ",") {
+			id, err := strconv.ParseUint(strings.TrimSpace(h), 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("Bad handle id %q in -handle", h)
+			}
+			filter.Handles = append(filter.Handles, nanolog.Handle(id))
+		}
+	}
+
+	if f.match != "" {
+		re, err := regexp.Compile(f.match)
+		if err != nil {
+			return nil, err
+		}
+		filter.Format = re
+	}
+
+	if f.level != "" {
+		level, err := nanolog.ParseLevel(f.level)
+		if err != nil {
+			return nil, err
+		}
+		filter.MinLevel = &level
+	}
+
+	for _, w := range f.where {
+		p, err := reader.ParsePredicate(w)
+		if err != nil {
+			return nil, err
+		}
+		filter.Args = append(filter.Args, p)
+	}
+
+	return filter, nil
+}
+
+// cat implements "nanolog cat [flags] [file...]", which inflates each file in
+// turn
+func cat(name string, args []string) (err error) {
+	fs := newFlagSet(name, "[file...]")
+
+	var out outputFlags
+	var filters filterFlags
+	var follow bool
+	var indexName string
+	var since, until string
+	var entry int64
+	out.register(fs)
+	filters.register(fs, true)
+	fs.BoolVar(&follow, "follow", false, "Keep reading as the file grows, following it across rotation")
+	fs.StringVar(&indexName, "index", "", "Index file written alongside the log, used to jump to -since or -entry")
+	fs.StringVar(&since, "since", "", "Start at the first entry logged at or after this RFC 3339 time")
+	fs.StringVar(&until, "until", "", "Stop at the first entry logged after this RFC 3339 time")
+	fs.Int64Var(&entry, "entry", -1, "Start at the entry with this number, counting from 0")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	if err := out.check(); err != nil {
+		return err
+	}
+
+	filter, err := filters.filter()
+	if err != nil {
+		return err
+	}
+
+	var sinceTime, untilTime time.Time
+	if since != "" {
+		if sinceTime, err = time.Parse(time.RFC3339Nano, since); err != nil {
+			return err
+		}
+	}
+	if until != "" {
+		if untilTime, err = time.Parse(time.RFC3339Nano, until); err != nil {
+			return err
+		}
+	}
+
+	seek := since != "" || entry >= 0
+	names := inputNames(fs)
+
+	if (seek || follow || indexName != "") && len(names) > 1 {
+		return errors.New("-follow, -index, -since and -entry work on a single file")
+	}
+	if seek && follow {
+		return errors.New("-since and -entry can't be used with -follow")
+	}
+	if follow && names[0] == "-" {
+		return errors.New("-follow needs a file name, not the standard input")
+	}
+
+	outfile, err := openOutput(out.out)
+	if err != nil {
+		return err
+	}
+	defer closeOutput(outfile, &err)
+
+	for _, n := range names {
+		var r *reader.Reader
+
+		if follow {
+			f, err := reader.Follow(n)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			r = newReader(f, outfile)
+		} else {
+			infile, err := openInput(n)
+			if err != nil {
+				return err
+			}
+			defer closeInput(infile)
+
+			if seek {
+				if r, err = seekReader(infile, outfile, indexName, sinceTime, entry); err != nil {
+					return inputError(n, err)
+				}
+			} else {
+				r = newReader(infile, outfile)
+			}
+		}
+
+		if !untilTime.IsZero() {
+			r.SetUntil(untilTime)
+		}
+		if filter != nil {
+			if err := r.SetFilter(filter); err != nil {
+				return err
+			}
+		}
+
+		if err := out.inflate(r); err != nil {
+			return inputError(n, err)
+		}
+	}
+
+	return nil
+}
+
+// seekReader returns a reader positioned at the since time or entry number,
+// using the index file when there is one. Without an index it still works, it
+// just decodes from the start.
+func seekReader(infile io.ReadSeeker, w io.Writer, indexName string, since time.Time, entry int64) (*reader.Reader, error) {
+	idx := &reader.Index{}
+
+	if indexName != "" {
+		idxfile, err := os.Open(indexName)
+		if err != nil {
+			return nil, err
+		}
+		defer idxfile.Close()
+
+		if idx, err = reader.ReadIndex(idxfile); err != nil {
+			return nil, fmt.Errorf("%s: %v", indexName, err)
+		}
+	}
+
+	idx.Dictionary = dict
+
+	if !since.IsZero() {
+		return idx.SeekTime(infile, w, since)
+	}
+
+	return idx.SeekEntry(infile, w, uint64(entry))
+}
@@ -0,0 +1,103 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// convertExts are the extensions given to converted files, by output format
+var convertExts = map[string]string{
+	"json": ".jsonl",
+	"text": ".log",
+}
+
+// convert implements "nanolog convert [flags] [file...]", which turns each log
+// file into a text or JSON Lines file next to it, e.g. foo.clog into foo.jsonl.
+// With -o all of the files are converted into that one file instead.
+func convert(name string, args []string) error {
+	fs := newFlagSet(name, "[file...]")
+
+	var to string
+	var out outputFlags
+	fs.StringVar(&to, "to", "json", "Output format, either json or text")
+	fs.StringVar(&out.out, "o", "", "Write all of the output to this file instead of one file per input")
+	fs.StringVar(&out.format, "format", "", "Output each log entry using this text/template, implies -to text")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	ext, ok := convertExts[to]
+	if !ok {
+		return fmt.Errorf("Unknown output format %q, must be json or text", to)
+	}
+	if out.format != "" {
+		ext = convertExts["text"]
+	}
+	out.asJSON = ext == convertExts["json"]
+
+	if err := out.check(); err != nil {
+		return err
+	}
+
+	names := inputNames(fs)
+
+	if out.out != "" {
+		return convertFiles(names, out.out, &out)
+	}
+
+	for _, n := range names {
+		outName := "-"
+		if n != "-" {
+			outName = strings.TrimSuffix(n, filepath.Ext(n)) + ext
+			if outName == n {
+				return fmt.Errorf("%s: Output would overwrite the input, use -o", n)
+			}
+		}
+
+		if err := convertFiles([]string{n}, outName, &out); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// convertFiles inflates the named files, in order, into the output file
+func convertFiles(names []string, outName string, out *outputFlags) (err error) {
+	outfile, err := openOutput(outName)
+	if err != nil {
+		return err
+	}
+	defer closeOutput(outfile, &err)
+
+	for _, n := range names {
+		infile, err := openInput(n)
+		if err != nil {
+			return err
+		}
+
+		err = out.inflate(newReader(infile, outfile))
+		closeInput(infile)
+
+		if err != nil {
+			return inputError(n, err)
+		}
+	}
+
+	return nil
+}
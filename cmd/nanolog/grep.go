@@ -0,0 +1,83 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+// grep implements "nanolog grep [flags] pattern [file...]", which outputs the
+// entries whose format string matches the pattern. Like grep(1) it exits with
+// status 1 when nothing matched.
+func grep(name string, args []string) (err error) {
+	fs := newFlagSet(name, "pattern [file...]")
+
+	var out outputFlags
+	var filters filterFlags
+	out.register(fs)
+	filters.register(fs, false)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	if err := out.check(); err != nil {
+		return err
+	}
+
+	filters.match = fs.Arg(0)
+	filter, err := filters.filter()
+	if err != nil {
+		return err
+	}
+
+	names := fs.Args()[1:]
+	if len(names) == 0 {
+		names = []string{"-"}
+	}
+
+	outfile, err := openOutput(out.out)
+	if err != nil {
+		return err
+	}
+	defer closeOutput(outfile, &err)
+
+	// every entry written produces some output, so counting bytes is enough to
+	// tell whether anything matched
+	cw := &countWriter{w: outfile}
+
+	for _, n := range names {
+		infile, err := openInput(n)
+		if err != nil {
+			return err
+		}
+		defer closeInput(infile)
+
+		r := newReader(infile, cw)
+		if err := r.SetFilter(filter); err != nil {
+			return err
+		}
+
+		if err := out.inflate(r); err != nil {
+			return inputError(n, err)
+		}
+	}
+
+	if cw.n == 0 {
+		return errNegative
+	}
+
+	return nil
+}
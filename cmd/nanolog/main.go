@@ -0,0 +1,268 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command nanolog reads the binary logs written by the nanolog package.
+//
+//	nanolog <command> [flags] [file...]
+//
+// Files are read in order; with no files, or a file named "-", the standard
+// input is read. Output goes to the standard output unless -o is given.
+//
+// The exit status is 0 on success, 1 when the command ran but the answer was
+// negative (e.g. grep found nothing, verify found a damaged file or recover
+// found a damaged ring) and 2 on any error.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/ScottMansfield/nanolog"
+	"github.com/ScottMansfield/nanolog/reader"
+)
+
+const (
+	exitOK       = 0
+	exitNegative = 1
+	exitError    = 2
+)
+
+// errNegative is returned by a command that ran fine but whose answer is no.
+// It sets the exit status without printing anything.
+var errNegative = errors.New("negative result")
+
+type command struct {
+	name    string
+	summary string
+	run     func(name string, args []string) error
+}
+
+var commands = []command{
+	{"cat", "inflate log files into text, JSON or a template", cat},
+	{"grep", "output only the entries matching a format pattern and conditions", grep},
+	{"merge", "interleave several log files ordered by time", merge},
+	{"stats", "summarize entry counts and sizes per log line", stats},
+	{"schema", "output the log lines defined in log files as JSON", schema},
+	{"verify", "check that log files can be decoded", verify},
+	{"convert", "convert log files into text or JSON files", convert},
+	{"recover", "turn a ring file into a normal log", recoverRing},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: nanolog <command> [flags] [file...]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, `Run "nanolog <command> -h" for the flags of a command.`)
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) == 0 {
+		usage()
+		return exitError
+	}
+
+	switch args[0] {
+	case "-h", "-help", "--help", "help":
+		usage()
+		return exitOK
+	}
+
+	for _, c := range commands {
+		if c.name != args[0] {
+			continue
+		}
+
+		err := c.run(c.name, args[1:])
+		switch {
+		case err == nil:
+			return exitOK
+		case err == errNegative:
+			return exitNegative
+		case err == flag.ErrHelp:
+			return exitOK
+		case err == errUsage:
+			// the flag package already printed the problem and the usage
+			return exitError
+		default:
+			fmt.Fprintf(os.Stderr, "nanolog %s: %v\n", c.name, err)
+			return exitError
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "nanolog: unknown command %q\n\n", args[0])
+	usage()
+	return exitError
+}
+
+// errUsage is returned when the flags could not be parsed
+var errUsage = errors.New("usage")
+
+// dictName is the -dict flag shared by all of the commands
+var dictName string
+
+// dict is the dictionary named by -dict, or nil
+var dict *nanolog.Dictionary
+
+// newFlagSet returns a flag set for a command that reports parse errors instead
+// of exiting, so run can pick the exit status.
+func newFlagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: nanolog %s [flags] %s\n\n", name, args)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&dictName, "dict", "", "Dictionary the logs were written with, see nanolog.WithDictionary")
+	return fs
+}
+
+// parse parses the flags of a command, turning any failure into errUsage, and
+// loads the dictionary
+func parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return err
+		}
+		return errUsage
+	}
+
+	if dictName != "" {
+		return loadDictionary(dictName)
+	}
+
+	return nil
+}
+
+func loadDictionary(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	d, err := nanolog.ReadDictionary(f)
+	if err == nil {
+		// check it can be used before any reading starts
+		err = reader.New(strings.NewReader(""), ioutil.Discard).SetDictionary(d)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+
+	dict = d
+	return nil
+}
+
+// newReader creates a reader for the input that uses the -dict dictionary
+func newReader(in io.Reader, w io.Writer) *reader.Reader {
+	r := reader.New(in, w)
+	if dict != nil {
+		// already checked when it was loaded
+		r.SetDictionary(dict)
+	}
+	return r
+}
+
+// inputNames returns the input files named on the command line, defaulting to
+// the standard input
+func inputNames(fs *flag.FlagSet) []string {
+	if fs.NArg() == 0 {
+		return []string{"-"}
+	}
+	return fs.Args()
+}
+
+// openInput opens the named input file, or returns the standard input for "-".
+// The returned file is closed by closeInput.
+func openInput(name string) (*os.File, error) {
+	if name == "-" {
+		return os.Stdin, nil
+	}
+	return os.Open(name)
+}
+
+func closeInput(f *os.File) {
+	if f != os.Stdin {
+		f.Close()
+	}
+}
+
+// openOutput creates the named output file, or returns the standard output
+// when name is empty or "-". The returned file is closed by closeOutput.
+func openOutput(name string) (*os.File, error) {
+	if name == "" || name == "-" {
+		return os.Stdout, nil
+	}
+	return os.Create(name)
+}
+
+// closeOutput closes an output file opened by openOutput, keeping the first
+// error seen in err
+func closeOutput(f *os.File, err *error) {
+	if f == os.Stdout {
+		return
+	}
+	if cerr := f.Close(); cerr != nil && *err == nil {
+		*err = cerr
+	}
+}
+
+// displayName is the name of an input file as shown in the output
+func displayName(name string) string {
+	if name == "-" {
+		return "<stdin>"
+	}
+	return name
+}
+
+// inputError prefixes an error about an input file with the file name
+func inputError(name string, err error) error {
+	return fmt.Errorf("%s: %v", displayName(name), err)
+}
+
+// countWriter counts the bytes written through it
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// stringsFlag collects the values of a flag that can be given more than once
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ", ")
+}
+
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+
+	"github.com/ScottMansfield/nanolog/reader"
+)
+
+// merge implements "nanolog merge [flags] file...", which interleaves the
+// entries of all the files by time
+func merge(name string, args []string) (err error) {
+	fs := newFlagSet(name, "file...")
+
+	var out outputFlags
+	out.register(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	if err := out.check(); err != nil {
+		return err
+	}
+
+	stdin := false
+	var sources []reader.Source
+	for _, n := range inputNames(fs) {
+		if n == "-" {
+			if stdin {
+				return errors.New("The standard input can only be merged once")
+			}
+			stdin = true
+		}
+
+		infile, err := openInput(n)
+		if err != nil {
+			return err
+		}
+		defer closeInput(infile)
+
+		sources = append(sources, reader.Source{Name: n, R: infile, Dictionary: dict})
+	}
+
+	outfile, err := openOutput(out.out)
+	if err != nil {
+		return err
+	}
+	defer closeOutput(outfile, &err)
+
+	m := reader.NewMerger(outfile, sources...)
+	defer m.Close()
+
+	return out.inflate(m)
+}
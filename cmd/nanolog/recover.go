@@ -0,0 +1,84 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/ScottMansfield/nanolog/reader"
+)
+
+// recoverRing implements "nanolog recover [flags] ring-file", which turns a ring
+// file written with nanolog.WithRing into a normal log that the other commands
+// can read. It exits with status 1 if the ring was damaged, after writing out
+// what could be recovered.
+func recoverRing(name string, args []string) (err error) {
+	fs := newFlagSet(name, "ring-file")
+
+	var outName string
+	var quiet bool
+	fs.StringVar(&outName, "o", "", "Write the log to this file instead of the standard output")
+	fs.BoolVar(&quiet, "q", false, "Don't report what was recovered on the standard error")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+	n := fs.Arg(0)
+
+	infile, err := openInput(n)
+	if err != nil {
+		return err
+	}
+	defer closeInput(infile)
+
+	data, err := ioutil.ReadAll(infile)
+	if err != nil {
+		return inputError(n, err)
+	}
+
+	outfile, err := openOutput(outName)
+	if err != nil {
+		return err
+	}
+	defer closeOutput(outfile, &err)
+
+	w := bufio.NewWriter(outfile)
+
+	rec, err := reader.RecoverRing(data, w)
+	if err != nil {
+		return inputError(n, err)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if !quiet {
+		fmt.Fprintf(os.Stderr, "%s: recovered %d bytes of log lines and %d records, %d bytes were overwritten\n",
+			displayName(n), rec.LineBytes, rec.Records, rec.Overwritten)
+	}
+	if rec.Damage != "" {
+		fmt.Fprintf(os.Stderr, "%s: damaged: %s\n", displayName(n), rec.Damage)
+		return errNegative
+	}
+
+	return nil
+}
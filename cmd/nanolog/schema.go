@@ -0,0 +1,69 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/ScottMansfield/nanolog"
+)
+
+// fileSchema is the JSON output of schema for one file
+type fileSchema struct {
+	File  string               `json:"file"`
+	Lines []nanolog.LineSchema `json:"lines"`
+}
+
+// schema implements "nanolog schema [flags] [file...]", which outputs the log
+// lines defined in each file as JSON
+func schema(name string, args []string) (err error) {
+	fs := newFlagSet(name, "[file...]")
+
+	var outName string
+	fs.StringVar(&outName, "o", "", "Write the output to this file instead of the standard output")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	outfile, err := openOutput(outName)
+	if err != nil {
+		return err
+	}
+	defer closeOutput(outfile, &err)
+
+	enc := json.NewEncoder(outfile)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+
+	for _, n := range inputNames(fs) {
+		infile, err := openInput(n)
+		if err != nil {
+			return err
+		}
+		defer closeInput(infile)
+
+		lines, err := newReader(infile, ioutil.Discard).Schema()
+		if err != nil {
+			return inputError(n, err)
+		}
+
+		if err := enc.Encode(fileSchema{File: displayName(n), Lines: lines}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
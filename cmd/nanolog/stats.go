@@ -0,0 +1,110 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ScottMansfield/nanolog/reader"
+)
+
+// fileStats is the JSON output of stats for one file
+type fileStats struct {
+	File string `json:"file"`
+	*reader.Stats
+}
+
+// stats implements "nanolog stats [flags] [file...]", which summarizes the
+// entries in each file per log line
+func stats(name string, args []string) (err error) {
+	fs := newFlagSet(name, "[file...]")
+
+	var outName string
+	var asJSON bool
+	fs.StringVar(&outName, "o", "", "Write the output to this file instead of the standard output")
+	fs.BoolVar(&asJSON, "json", false, "Output the statistics as JSON, one object per file")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	outfile, err := openOutput(outName)
+	if err != nil {
+		return err
+	}
+	defer closeOutput(outfile, &err)
+
+	names := inputNames(fs)
+
+	for i, n := range names {
+		infile, err := openInput(n)
+		if err != nil {
+			return err
+		}
+		defer closeInput(infile)
+
+		s, err := newReader(infile, ioutil.Discard).Stats()
+		if err != nil {
+			return inputError(n, err)
+		}
+
+		if asJSON {
+			enc := json.NewEncoder(outfile)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(fileStats{File: displayName(n), Stats: s}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if len(names) > 1 {
+			if i > 0 {
+				fmt.Fprintln(outfile)
+			}
+			fmt.Fprintf(outfile, "==> %s <==\n", displayName(n))
+		}
+
+		if err := writeStats(outfile, s); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeStats writes the statistics for one file as a table
+func writeStats(w io.Writer, s *reader.Stats) error {
+	fmt.Fprintf(w, "Size:    %d bytes (%d in log line records)\n", s.Size, s.LineBytes)
+	fmt.Fprintf(w, "Entries: %d\n", s.Entries)
+	if !s.First.IsZero() {
+		fmt.Fprintf(w, "First:   %s\n", s.First.Format(time.RFC3339Nano))
+		fmt.Fprintf(w, "Last:    %s\n", s.Last.Format(time.RFC3339Nano))
+	}
+	fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Handle\tEntries\tBytes\tAvg\tShare\t\tFormat")
+
+	for _, hs := range s.Handles {
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%.1f\t%.2f%%\t\t%q\n",
+			hs.Handle, hs.Entries, hs.Bytes, hs.AvgEntryBytes(), hs.Share*100, hs.Format)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,127 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ScottMansfield/nanolog/reader"
+)
+
+// fileVerification is the JSON output of verify for one file
+type fileVerification struct {
+	File string `json:"file"`
+	OK   bool   `json:"ok"`
+	*reader.Verification
+	// Offset and Error describe the first bad record, if there is one
+	Offset *int64 `json:"offset,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// verify implements "nanolog verify [flags] [file...]", which checks that each
+// file can be decoded. It exits with status 1 if any file is damaged.
+func verify(name string, args []string) (err error) {
+	fs := newFlagSet(name, "[file...]")
+
+	var outName string
+	var asJSON, quiet, closed bool
+	fs.StringVar(&outName, "o", "", "Write the output to this file instead of the standard output")
+	fs.BoolVar(&asJSON, "json", false, "Output the result as JSON, one object per file")
+	fs.BoolVar(&quiet, "q", false, "Only report damaged files")
+	fs.BoolVar(&closed, "closed", false, "Also report files that don't end with a trailer, i.e. whose writer wasn't closed (see nanolog.WithTrailer)")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	outfile, err := openOutput(outName)
+	if err != nil {
+		return err
+	}
+	defer closeOutput(outfile, &err)
+
+	bad := false
+
+	for _, n := range inputNames(fs) {
+		infile, err := openInput(n)
+		if err != nil {
+			return err
+		}
+		defer closeInput(infile)
+
+		v, verr := newReader(infile, ioutil.Discard).Verify()
+
+		var offset int64
+		if verr == nil && closed && !v.Closed {
+			verr = &reader.VerifyError{Offset: v.Size, Err: errors.New("No trailer, the log was not closed")}
+		}
+		if verr != nil {
+			bad = true
+
+			ve, ok := verr.(*reader.VerifyError)
+			if !ok {
+				return inputError(n, verr)
+			}
+			offset = ve.Offset
+			verr = ve.Err
+		} else if quiet {
+			continue
+		}
+
+		if asJSON {
+			fv := fileVerification{File: displayName(n), OK: verr == nil, Verification: v}
+			if verr != nil {
+				fv.Offset = &offset
+				fv.Error = verr.Error()
+			}
+
+			if err := json.NewEncoder(outfile).Encode(fv); err != nil {
+				return err
+			}
+			continue
+		}
+
+		counts := fmt.Sprintf("%d bytes, %d log lines, %d entries", v.Size, v.Lines, v.Entries)
+		if v.IndexPoints > 0 {
+			counts += fmt.Sprintf(", %d index points", v.IndexPoints)
+		}
+		if v.Dictionaries > 0 {
+			counts += fmt.Sprintf(", %d dictionary references", v.Dictionaries)
+		}
+		if v.Trailers > 0 {
+			counts += fmt.Sprintf(", %d trailers", v.Trailers)
+		}
+		if v.Closed {
+			counts += ", closed"
+		}
+
+		if verr != nil {
+			_, err = fmt.Fprintf(outfile, "%s: BAD at offset %d: %v (after %s)\n", displayName(n), offset, verr, counts)
+		} else {
+			_, err = fmt.Fprintf(outfile, "%s: OK (%s)\n", displayName(n), counts)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if bad {
+		return errNegative
+	}
+
+	return nil
+}
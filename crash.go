@@ -0,0 +1,115 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nanolog
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"sync"
+	"syscall"
+)
+
+// The entries logged last before a crash are the ones most likely to still be
+// in the buffer. None of these helpers are installed by default.
+
+// PanicLogName is the name of the log line Recover logs panics with
+const PanicLogName = "nanolog.panic"
+
+// osExit is replaced in tests
+var osExit = os.Exit
+
+// HandleSignals flushes the default log writer, and any others given, when the
+// process gets SIGINT or SIGTERM. The signal is then sent again so it has the
+// effect it would have had without this, which for a program that doesn't
+// handle it is to exit. Only the first signal is handled. The returned function
+// stops the handling.
+func HandleSignals(lws ...LogWriter) (stop func()) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case sig := <-c:
+			defaultLogWriter.Flush()
+			for _, lw := range lws {
+				lw.Flush()
+			}
+
+			signal.Stop(c)
+			raise(sig)
+
+		case <-done:
+			signal.Stop(c)
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// raise sends the signal to the process, exiting if that isn't supported
+func raise(sig os.Signal) {
+	p, err := os.FindProcess(os.Getpid())
+	if err == nil {
+		err = p.Signal(sig)
+	}
+	if err != nil {
+		osExit(1)
+	}
+}
+
+// Recover logs a panic to the default log writer, flushes it and panics again
+// with the same value, so the program still crashes the way it would have. It
+// has to be deferred directly, at the top of each goroutine:
+//
+//	go func() {
+//		defer nanolog.Recover()
+//		...
+//	}()
+//
+// The panic value and the stack are logged at LevelFatal with a log line named
+// PanicLogName.
+func Recover() {
+	if r := recover(); r != nil {
+		defaultLogWriter.logPanic(r, debug.Stack())
+		panic(r)
+	}
+}
+
+func (lw *logWriter) logPanic(r interface{}, stack []byte) {
+	// a named log line is only added once. It has no source location since
+	// it isn't added by the caller, and the stack says where the panic was.
+	l := parseLogLine("panic: %s\n%s")
+	l.Name = PanicLogName
+	l.Level = LevelFatal
+	h := lw.addLine(l)
+
+	lw.Log(h, fmt.Sprint(r), string(stack))
+	lw.Flush()
+}
+
+// Fatal logs an entry to the default log writer, closes it so everything is
+// written out and exits the program with status 1.
+func Fatal(handle Handle, args ...interface{}) {
+	defaultLogWriter.Log(handle, args...)
+	defaultLogWriter.Close()
+	osExit(1)
+}
@@ -0,0 +1,125 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nanolog
+
+import (
+	"bytes"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// withDefault swaps in a new default log writer for the test
+func withDefault(t *testing.T, out *bytes.Buffer) *logWriter {
+	old := defaultLogWriter
+	defaultLogWriter = newLogWriter()
+	defaultLogWriter.SetWriter(out)
+	t.Cleanup(func() { defaultLogWriter = old })
+	return defaultLogWriter
+}
+
+func TestRecover(t *testing.T) {
+	buf := &bytes.Buffer{}
+	lw := withDefault(t, buf)
+	Configure(WithSourceLocations(true))
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("Expected the panic to carry on but got %v", r)
+			}
+		}()
+		defer Recover()
+
+		panic("boom")
+	}()
+
+	if !bytes.Contains(buf.Bytes(), []byte(PanicLogName)) || !bytes.Contains(buf.Bytes(), []byte("boom")) {
+		t.Fatalf("Expected the panic to be logged and flushed but got %q", buf.String())
+	}
+	if !strings.Contains(buf.String(), "TestRecover") {
+		t.Fatalf("Expected the stack to be logged but got %q", buf.String())
+	}
+
+	// the stack says where the panic was, not the source location
+	h, _ := lw.LookupName(PanicLogName)
+	if l := lw.reg.get(h); l.File != "" || l.Func != "" {
+		t.Fatalf("Expected no source location for the panic log line but got %s:%d %s", l.File, l.Line, l.Func)
+	}
+}
+
+func TestFatal(t *testing.T) {
+	buf := &bytes.Buffer{}
+	lw := withDefault(t, buf)
+
+	code := -1
+	osExit = func(c int) { code = c }
+	defer func() { osExit = os.Exit }()
+
+	h := AddLeveledLogger(LevelFatal, "fatal %s")
+	Fatal(h, "bye")
+
+	if code != 1 {
+		t.Fatalf("Expected an exit with status 1 but got %d", code)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("bye")) {
+		t.Fatalf("Expected the entry to be written out but got %q", buf.String())
+	}
+	if !lw.closed {
+		t.Fatal("Expected the default log writer to be closed")
+	}
+}
+
+func TestHandleSignals(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Signals can't be sent to the process on windows")
+	}
+
+	buf := &lockedBuffer{}
+	lw := New()
+	lw.SetWriter(buf)
+	h := lw.AddLogger("before the signal")
+	lw.Log(h)
+
+	// keep the signal from killing the test
+	c := make(chan os.Signal, 2)
+	signal.Notify(c, syscall.SIGTERM)
+	defer signal.Stop(c)
+
+	stop := HandleSignals(lw)
+	defer stop()
+
+	p, _ := os.FindProcess(os.Getpid())
+	if err := p.Signal(syscall.SIGTERM); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// once for the signal and once more when it is sent again
+	for i := 0; i < 2; i++ {
+		select {
+		case <-c:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected the signal twice")
+		}
+	}
+
+	if buf.Len() == 0 {
+		t.Fatal("Expected the log to be flushed")
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nanolog
+
+import (
+	"errors"
+	"os"
+	"sync/atomic"
+)
+
+// Handles given out by a LogWriter in debug mode carry a tag for the writer in
+// their top bits. The tag is stripped before the handle is written, so the log
+// is the same either way.
+//
+// Log looks the handle up as usual first, so a writer not in debug mode pays
+// next to nothing: a tagged handle is far past the end of the registry, and a
+// plain one is only checked if it is for a log line added in debug mode.
+const (
+	handleTagShift  = 24
+	handleIndexMask = 1<<handleTagShift - 1
+	maxHandleTag    = 1<<(32-handleTagShift) - 1
+
+	// checkNone is checkFrom while debug mode is off
+	checkNone = ^uint32(0)
+)
+
+// DebugEnv is the environment variable that turns on debug mode for every
+// LogWriter, including the default one, when it is set to anything but "".
+// It is the way to get checked handles from log lines added in package
+// initialization, before Configure can be called.
+const DebugEnv = "NANOLOG_DEBUG"
+
+var (
+	// ErrUnknownHandle is returned by Log for a handle that was never added
+	ErrUnknownHandle = errors.New("Unknown handle")
+	// ErrForeignHandle is returned by Log for a handle that was added to a
+	// different LogWriter. It is only detected if that writer was in debug
+	// mode (see WithDebug).
+	ErrForeignHandle = errors.New("Handle was added to a different LogWriter")
+)
+
+// lastHandleTag is the tag given to the last LogWriter created
+var lastHandleTag uint32
+
+// nextHandleTag returns the tag for a new LogWriter. Tags are never 0, which is
+// what a handle from a writer not in debug mode has, and they wrap around
+// after 255 writers.
+func nextHandleTag() uint32 {
+	return (atomic.AddUint32(&lastHandleTag, 1)-1)%maxHandleTag + 1
+}
+
+// WithDebug turns on debug mode, in which the handles returned by AddLogger
+// and the lookups carry the identity of the writer. Passing one of them to
+// Log on another writer returns ErrForeignHandle instead of logging garbage,
+// and so does a plain handle from a writer not in debug mode. Handles given out
+// before debug mode was turned on keep working but aren't checked, and a
+// writer in debug mode allows at most 1<<24 log lines.
+func WithDebug(enabled bool) Option {
+	return func(lw *logWriter) {
+		if !enabled {
+			atomic.StoreUint32(&lw.debugTag, 0)
+			atomic.StoreUint32(&lw.checkFrom, checkNone)
+			return
+		}
+
+		if atomic.LoadUint32(&lw.debugTag) == 0 {
+			atomic.StoreUint32(&lw.checkFrom, lw.reg.len())
+			atomic.StoreUint32(&lw.debugTag, lw.tag)
+		}
+	}
+}
+
+func debugFromEnv() bool {
+	return os.Getenv(DebugEnv) != ""
+}
+
+// handle returns the handle given out for the log line at idx
+func (lw *logWriter) handle(idx Handle) Handle {
+	tag := atomic.LoadUint32(&lw.debugTag)
+	if tag == 0 {
+		return idx
+	}
+
+	if idx > handleIndexMask {
+		panic("Too many loggers for debug mode")
+	}
+
+	return idx | Handle(tag<<handleTagShift)
+}
+
+// resolve is the slow path of a handle lookup, for a handle that isn't in the
+// registry as it is or has to carry the tag. It returns the log line and the id
+// it is written with.
+func (lw *logWriter) resolve(h Handle) (Handle, *Logger, error) {
+	tag := uint32(h) >> handleTagShift
+	if tag == 0 {
+		// a plain handle for a log line added in debug mode was given out by
+		// another writer
+		if uint32(h) < lw.reg.len() {
+			return 0, nil, ErrForeignHandle
+		}
+		return 0, nil, ErrUnknownHandle
+	}
+	if tag != lw.tag {
+		return 0, nil, ErrForeignHandle
+	}
+
+	idx := h & handleIndexMask
+	if uint32(idx) >= lw.reg.len() {
+		return 0, nil, ErrUnknownHandle
+	}
+
+	return idx, lw.reg.get(idx), nil
+}
@@ -0,0 +1,159 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nanolog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDebugHandles(t *testing.T) {
+	buf := &bytes.Buffer{}
+	lw := New(WithDebug(true))
+	lw.SetWriter(buf)
+
+	h := lw.AddLogger("debug %u8")
+	if h>>handleTagShift == 0 {
+		t.Fatalf("Expected a tagged handle but got %#x", uint32(h))
+	}
+	if lh, ok := lw.Lookup("debug %u8"); !ok || lh != h {
+		t.Fatalf("Expected Lookup to return %#x but got %#x", uint32(h), uint32(lh))
+	}
+
+	if err := lw.Log(h, uint8(1)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	lw.Flush()
+
+	// the log is the same as without debug mode
+	expected := append(logLineRecord(0, parseLogLine("debug %u8")), byte(ETLogEntry), 0, 0, 0, 0, 1)
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Fatalf("Expected % X but got % X", expected, buf.Bytes())
+	}
+
+	if d := lw.DebugDump(h); !strings.Contains(d, `"format":"debug %u8"`) {
+		t.Fatalf("Expected DebugDump to describe the log line but got %s", d)
+	}
+}
+
+func TestDebugForeignHandle(t *testing.T) {
+	a := New(WithDebug(true))
+	b := New(WithDebug(true))
+	plain := New()
+
+	h := a.AddLogger("owned by a")
+	b.AddLogger("owned by b")
+	plain.AddLogger("owned by plain")
+
+	if err := b.Log(h); err != ErrForeignHandle {
+		t.Fatalf("Expected ErrForeignHandle from another debug writer but got %v", err)
+	}
+	if err := plain.Log(h); err != ErrForeignHandle {
+		t.Fatalf("Expected ErrForeignHandle from a plain writer but got %v", err)
+	}
+	if d := b.DebugDump(h); d != ErrForeignHandle.Error() {
+		t.Fatalf("Expected DebugDump to report the foreign handle but got %s", d)
+	}
+	if err := a.Log(h); err != nil {
+		t.Fatalf("Expected the owning writer to log but got %v", err)
+	}
+}
+
+func TestDebugOff(t *testing.T) {
+	lw := New(WithDebug(true))
+	h := lw.AddLogger("before")
+
+	WithDebug(false)(lw.(*logWriter))
+
+	if h2 := lw.AddLogger("after"); h2 != 1 {
+		t.Fatalf("Expected a plain handle after debug mode is off but got %#x", uint32(h2))
+	}
+	if err := lw.Log(h); err != nil {
+		t.Fatalf("Expected the tagged handle to keep working but got %v", err)
+	}
+
+	// handles given out while debug mode was off keep working once it is on
+	WithDebug(true)(lw.(*logWriter))
+	if err := lw.Log(Handle(1)); err != nil {
+		t.Fatalf("Expected the plain handle to keep working but got %v", err)
+	}
+	if h3 := lw.AddLogger("again"); h3>>handleTagShift == 0 || lw.Log(h3&handleIndexMask) != ErrForeignHandle {
+		t.Fatalf("Expected a tagged handle that is required once debug mode is back on, got %#x", uint32(h3))
+	}
+}
+
+func TestUnknownHandle(t *testing.T) {
+	lw := New(WithDebug(true))
+	h := lw.AddLogger("only")
+
+	if err := lw.Log(h + 1); err != ErrUnknownHandle {
+		t.Fatalf("Expected ErrUnknownHandle but got %v", err)
+	}
+	if err := New().Log(Handle(1 << 20)); err != ErrUnknownHandle {
+		t.Fatalf("Expected ErrUnknownHandle but got %v", err)
+	}
+}
+
+func TestUnaddedHandle(t *testing.T) {
+	buf := &bytes.Buffer{}
+	lw := New()
+	lw.SetWriter(buf)
+	lw.AddLogger("only %u8")
+	lw.Flush()
+	start := buf.Len()
+
+	// the handles have room in the registry but were never added
+	if err := lw.Log(Handle(5)); err != ErrUnknownHandle {
+		t.Fatalf("Expected ErrUnknownHandle but got %v", err)
+	}
+	if err := lw.Log(Handle(5), uint8(1)); err != ErrUnknownHandle {
+		t.Fatalf("Expected ErrUnknownHandle with args but got %v", err)
+	}
+	if d := lw.DebugDump(Handle(5)); d != ErrUnknownHandle.Error() {
+		t.Fatalf("Expected DebugDump to report the unknown handle but got %s", d)
+	}
+
+	lw.Flush()
+	if buf.Len() != start {
+		t.Fatalf("Expected nothing to be written but got % X", buf.Bytes()[start:])
+	}
+}
+
+func TestDebugDefaultHandle(t *testing.T) {
+	buf := &bytes.Buffer{}
+	old := defaultLogWriter
+	defaultLogWriter = newLogWriter()
+	defer func() { defaultLogWriter = old }()
+
+	h := AddLogger("owned by the default writer")
+
+	lw := New(WithDebug(true))
+	lw.SetWriter(buf)
+	lw.AddLogger("owned by lw")
+
+	// the plain handle has an index lw has too
+	if err := lw.Log(h); err != ErrForeignHandle {
+		t.Fatalf("Expected ErrForeignHandle from a plain handle but got %v", err)
+	}
+	if d := lw.DebugDump(h); d != ErrForeignHandle.Error() {
+		t.Fatalf("Expected DebugDump to report the foreign handle but got %s", d)
+	}
+
+	lw.Flush()
+	if expected := logLineRecord(0, parseLogLine("owned by lw")); !bytes.Equal(buf.Bytes(), expected) {
+		t.Fatalf("Expected no entry to be written but got % X", buf.Bytes())
+	}
+}
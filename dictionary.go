@@ -0,0 +1,219 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nanolog
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+// A dictionary holds log line records that are left out of the log, for logs
+// so small that the log lines would take up most of the space. It is made of
+// ETLogLine records one after another, as written by WriteDictionary. A log
+// written with a dictionary starts with a reference to it, formatted as
+// follows:
+//
+//  - type: 1 byte - ETDictionary (5)
+//  - hash: 8 bytes - little endian uint64, the first 8 bytes of the SHA-256 of the dictionary
+//
+// Log lines that are not in the dictionary, or differ from it, are still
+// written to the log as usual, so a log stays readable if the program changes
+// after the dictionary was made. A reader needs the dictionary to read the
+// entries for the log lines that were left out.
+
+// ErrBadDictionary is returned when a dictionary is not made of complete log
+// line records
+var ErrBadDictionary = errors.New("Bad dictionary format")
+
+// Dictionary is a set of log line records shared between a writer and a reader
+// ahead of time
+type Dictionary struct {
+	raw   []byte
+	hash  uint64
+	lines map[uint32][]byte
+}
+
+// ReadDictionary reads a dictionary written by WriteDictionary
+func ReadDictionary(r io.Reader) (*Dictionary, error) {
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewDictionary(raw)
+}
+
+// NewDictionary creates a dictionary from the bytes written by WriteDictionary
+func NewDictionary(raw []byte) (*Dictionary, error) {
+	sum := sha256.Sum256(raw)
+	d := &Dictionary{
+		raw:   raw,
+		hash:  binary.LittleEndian.Uint64(sum[:8]),
+		lines: make(map[uint32][]byte),
+	}
+
+	for b := raw; len(b) > 0; {
+		n, err := logLineRecordLen(b)
+		if err != nil {
+			return nil, err
+		}
+
+		d.lines[binary.LittleEndian.Uint32(b[1:5])] = b[:n]
+		b = b[n:]
+	}
+
+	return d, nil
+}
+
+// Hash identifies the dictionary. It is what a log written with the dictionary
+// refers to.
+func (d *Dictionary) Hash() uint64 {
+	return d.hash
+}
+
+// Bytes returns the dictionary as written by WriteDictionary
+func (d *Dictionary) Bytes() []byte {
+	return d.raw
+}
+
+// has checks whether the dictionary holds exactly this log line record
+func (d *Dictionary) has(idx uint32, rec []byte) bool {
+	return bytes.Equal(d.lines[idx], rec)
+}
+
+// logLineRecordLen returns the length of the log line record at the start of b
+func logLineRecordLen(b []byte) (int, error) {
+	off := 0
+
+	next := func(n int) ([]byte, error) {
+		if n < 0 || len(b)-off < n {
+			return nil, ErrBadDictionary
+		}
+		off += n
+		return b[off-n : off], nil
+	}
+	nextUint32 := func() (uint32, error) {
+		v, err := next(4)
+		if err != nil {
+			return 0, err
+		}
+		return binary.LittleEndian.Uint32(v), nil
+	}
+
+	// type and id
+	t, err := next(5)
+	if err != nil {
+		return 0, err
+	}
+	if EntryType(t[0]) != ETLogLine {
+		return 0, ErrBadDictionary
+	}
+
+	numsegs, err := nextUint32()
+	if err != nil {
+		return 0, err
+	}
+
+	hasAttrs := numsegs&SegsHasAttrs != 0
+	numsegs &^= SegsHasAttrs
+	if numsegs == 0 {
+		return 0, ErrBadDictionary
+	}
+
+	// kinds
+	if _, err := next(int(numsegs - 1)); err != nil {
+		return 0, err
+	}
+
+	for i := uint32(0); i < numsegs; i++ {
+		l, err := nextUint32()
+		if err != nil {
+			return 0, err
+		}
+		if _, err := next(int(l)); err != nil {
+			return 0, err
+		}
+	}
+
+	if hasAttrs {
+		numattrs, err := nextUint32()
+		if err != nil {
+			return 0, err
+		}
+
+		for i := uint32(0); i < numattrs; i++ {
+			// key
+			if _, err := next(1); err != nil {
+				return 0, err
+			}
+
+			l, err := nextUint32()
+			if err != nil {
+				return 0, err
+			}
+			if _, err := next(int(l)); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return off, nil
+}
+
+// WithDictionary leaves the log line records that are in the dictionary out of
+// the log and writes a reference to the dictionary in their place. Log lines
+// added before the option is applied, e.g. in the init functions of other
+// packages, are left out as well as long as no entries have been logged and no
+// writer has been set.
+func WithDictionary(d *Dictionary) Option {
+	return func(lw *logWriter) {
+		lw.dict = d
+
+		if !lw.rewriteHeader() {
+			lw.writeDictionaryRef()
+		}
+	}
+}
+
+// writeDictionaryRef writes the reference to the dictionary. The write lock must
+// be held.
+func (lw *logWriter) writeDictionaryRef() {
+	var b [9]byte
+	b[0] = byte(ETDictionary)
+	binary.LittleEndian.PutUint64(b[1:], lw.dict.hash)
+	lw.write(b[:])
+}
+
+// WriteDictionary calls LogWriter.WriteDictionary on the default log writer.
+func WriteDictionary(w io.Writer) error {
+	return defaultLogWriter.WriteDictionary(w)
+}
+
+func (lw *logWriter) WriteDictionary(w io.Writer) error {
+	buf := &bytes.Buffer{}
+
+	lw.writeLock.Lock()
+	lw.eachLogger(func(idx uint32, l Logger) {
+		buf.Write(lw.logLineRecord(idx, l))
+	})
+	lw.writeLock.Unlock()
+
+	_, err := buf.WriteTo(w)
+	return err
+}
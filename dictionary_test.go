@@ -0,0 +1,111 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nanolog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestDictionary(t *testing.T) {
+	// the dictionary is made by a run of the program that adds the same log lines
+	dictbuf := &bytes.Buffer{}
+	lw := New()
+	lw.AddLogger("first %i")
+	lw.AddLogger("second %s")
+	if err := lw.WriteDictionary(dictbuf); err != nil {
+		t.Fatalf("Got error writing dictionary: %v", err)
+	}
+
+	d, err := ReadDictionary(bytes.NewReader(dictbuf.Bytes()))
+	if err != nil {
+		t.Fatalf("Got error reading dictionary: %v", err)
+	}
+
+	logbuf := &bytes.Buffer{}
+	lw = New(WithDictionary(d))
+	lw.SetWriter(logbuf)
+	h1 := lw.AddLogger("first %i")
+	// same handle as in the dictionary, but a different format
+	lw.AddLogger("changed %s")
+	h3 := lw.AddLogger("third")
+	lw.Log(h1, 7)
+	lw.Flush()
+
+	expected := &bytes.Buffer{}
+	expected.WriteByte(byte(ETDictionary))
+	binary.Write(expected, binary.LittleEndian, d.Hash())
+	expected.Write(logLineRecord(1, parseLogLine("changed %s")))
+	expected.Write(logLineRecord(uint32(h3), parseLogLine("third")))
+	expected.Write([]byte{byte(ETLogEntry), 0, 0, 0, 0, 7, 0, 0, 0, 0, 0, 0, 0})
+
+	if !bytes.Equal(logbuf.Bytes(), expected.Bytes()) {
+		t.Fatalf("Unexpected log.\nExpected: % X\nGot: % X", expected.Bytes(), logbuf.Bytes())
+	}
+}
+
+func TestDictionaryAfterAddLogger(t *testing.T) {
+	dictbuf := &bytes.Buffer{}
+	lw := New()
+	lw.AddLogger("in the dictionary")
+	lw.WriteDictionary(dictbuf)
+
+	d, err := NewDictionary(dictbuf.Bytes())
+	if err != nil {
+		t.Fatalf("Got error reading dictionary: %v", err)
+	}
+
+	// like the default writer, which has log lines added in init functions
+	// before main can configure it
+	logbuf := &bytes.Buffer{}
+	lw = New()
+	lw.AddLogger("in the dictionary")
+	h := lw.AddLogger("not in the dictionary")
+	WithDictionary(d)(lw.(*logWriter))
+	lw.SetWriter(logbuf)
+	lw.Flush()
+
+	expected := &bytes.Buffer{}
+	expected.WriteByte(byte(ETDictionary))
+	binary.Write(expected, binary.LittleEndian, d.Hash())
+	expected.Write(logLineRecord(uint32(h), parseLogLine("not in the dictionary")))
+
+	if !bytes.Equal(logbuf.Bytes(), expected.Bytes()) {
+		t.Fatalf("Unexpected log.\nExpected: % X\nGot: % X", expected.Bytes(), logbuf.Bytes())
+	}
+}
+
+func TestDictionaryBadFormat(t *testing.T) {
+	good := logLineRecord(0, parseLogLine("line %u8 %s"))
+
+	tests := map[string][]byte{
+		"Truncated": good[:len(good)-1],
+		"NotALine":  {byte(ETLogEntry), 0, 0, 0, 0},
+		"Trailing":  append(append([]byte{}, good...), byte(ETLogLine)),
+	}
+
+	for name, raw := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewDictionary(raw); err != ErrBadDictionary {
+				t.Fatalf("Expected ErrBadDictionary but got %v", err)
+			}
+		})
+	}
+
+	if _, err := NewDictionary(good); err != nil {
+		t.Fatalf("Got error for a good dictionary: %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nanolog
+
+import (
+	"math"
+	"time"
+)
+
+// flushNever is the flush level that no log line reaches
+const flushNever Level = math.MaxInt8
+
+// WithFlushInterval flushes the log in the background every interval, so
+// entries don't sit in the buffer when nothing else flushes it. An interval of
+// 0 stops the background flushing. It is stopped by Close.
+func WithFlushInterval(interval time.Duration) Option {
+	return func(lw *logWriter) {
+		stopRunning(&lw.flushStop)
+
+		if interval > 0 && !lw.closed {
+			lw.flushStop = make(chan struct{})
+			go runEvery(time.NewTicker(interval), lw.flushStop, lw.Flush)
+		}
+	}
+}
+
+// stopRunning stops the runEvery that stop belongs to, if there is one
+func stopRunning(stop *chan struct{}) {
+	if *stop != nil {
+		close(*stop)
+		*stop = nil
+	}
+}
+
+// runEvery calls f on every tick until stop is closed
+func runEvery(t *time.Ticker, stop chan struct{}, f func() error) {
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			// errors will come up again on the next Log or Flush
+			f()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// WithFlushLevel flushes the log right after each entry for a log line at the
+// level or above, so e.g. errors are out before a crash. The level of the log
+// line is checked while the entry is written anyway, so other entries cost
+// nothing more. By default no entries are flushed this way.
+func WithFlushLevel(level Level) Option {
+	return func(lw *logWriter) {
+		lw.flushLevel = level
+	}
+}
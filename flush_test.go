@@ -0,0 +1,92 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nanolog
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lockedBuffer can be written by the background flush while the test reads it
+type lockedBuffer struct {
+	sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) Len() int {
+	b.Lock()
+	defer b.Unlock()
+	return b.buf.Len()
+}
+
+func TestFlushLevel(t *testing.T) {
+	buf := &bytes.Buffer{}
+	lw := New(WithFlushLevel(LevelError))
+	lw.SetWriter(buf)
+
+	hInfo := lw.AddLogger("info %i")
+	hErr := lw.AddLeveledLogger(LevelError, "error %i")
+	hFatal := lw.AddLeveledLogger(LevelFatal, "fatal %i")
+	lw.Flush()
+
+	start := buf.Len()
+
+	lw.Log(hInfo, 1)
+	if buf.Len() != start {
+		t.Fatal("Expected the info entry to stay in the buffer")
+	}
+
+	lw.Log(hErr, 2)
+	if buf.Len() != start+2*13 {
+		t.Fatalf("Expected both entries to be flushed with the error entry, got %d bytes", buf.Len()-start)
+	}
+
+	lw.Log(hFatal, 3)
+	if buf.Len() != start+3*13 {
+		t.Fatalf("Expected the fatal entry to be flushed, got %d bytes", buf.Len()-start)
+	}
+}
+
+func TestFlushInterval(t *testing.T) {
+	buf := &lockedBuffer{}
+	lw := New(WithFlushInterval(time.Millisecond))
+	lw.SetWriter(buf)
+
+	h := lw.AddLogger("tick %i")
+	lw.Log(h, 1)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for buf.Len() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the entry to be flushed in the background")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := lw.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if lw.(*logWriter).flushStop != nil {
+		t.Fatal("Expected Close to stop the background flush")
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nanolog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// An index file lets a reader jump into the middle of a log instead of decoding
+// it from the start. It is a separate stream made of a copy of every log line
+// record in the log plus periodic checkpoint records, formatted as follows:
+//
+//  - type:      1 byte - ETIndex (4)
+//  - offset:    8 bytes - little endian uint64 position of a log entry record in the log
+//  - entry:     8 bytes - little endian uint64 number of entries before that record
+//  - timestamp: 8 bytes - nanoseconds since the Unix epoch as little endian int64
+//
+// The offsets count every byte the LogWriter has written, so the index only
+// matches the log if all of it goes to a single writer.
+
+// WithIndex writes an index for the log to w, with a checkpoint every so many
+// entries. Log lines that were added before the option was applied are written
+// to the index right away.
+func WithIndex(w io.Writer, every int) Option {
+	return func(lw *logWriter) {
+		if every < 1 {
+			every = 1
+		}
+
+		lw.index = bufio.NewWriter(w)
+		lw.indexOut = w
+		lw.indexEvery = uint64(every)
+
+		lw.eachLogger(func(idx uint32, l Logger) {
+			lw.index.Write(lw.logLineRecord(idx, l))
+		})
+	}
+}
+
+// checkpoint writes an index record for the entry about to be written if it is
+// due. The write lock must be held.
+func (lw *logWriter) checkpoint(now time.Time) {
+	if lw.entries%lw.indexEvery == 0 {
+		if now.IsZero() {
+			now = time.Now()
+		}
+
+		var b [25]byte
+		b[0] = byte(ETIndex)
+		binary.LittleEndian.PutUint64(b[1:], uint64(lw.offset))
+		binary.LittleEndian.PutUint64(b[9:], lw.entries)
+		binary.LittleEndian.PutUint64(b[17:], uint64(now.UnixNano()))
+
+		lw.index.Write(b[:])
+	}
+}
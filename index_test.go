@@ -0,0 +1,70 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nanolog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestIndex(t *testing.T) {
+	logbuf, idxbuf := &bytes.Buffer{}, &bytes.Buffer{}
+
+	lw := New()
+	// added before the index is turned on
+	h := lw.AddLogger("%u8 %s")
+	WithIndex(idxbuf, 3)(lw.(*logWriter))
+	lw.SetWriter(logbuf)
+
+	for i := 0; i < 7; i++ {
+		lw.Log(h, uint8(i), "foo")
+	}
+	lw.Flush()
+
+	idx := idxbuf.Bytes()
+	header := logLineRecord(uint32(h), parseLogLine("%u8 %s"))
+
+	if !bytes.HasPrefix(idx, header) {
+		t.Fatalf("Expected the index to start with the log line record.\nExpected: % X\nGot: % X", header, idx)
+	}
+	idx = idx[len(header):]
+
+	if len(idx) != 3*25 {
+		t.Fatalf("Expected 3 checkpoints but got % X", idx)
+	}
+
+	log := logbuf.Bytes()
+
+	for i := 0; i < 3; i++ {
+		if idx[0] != byte(ETIndex) {
+			t.Fatalf("Expected ETIndex record but got %v", idx[0])
+		}
+
+		off := binary.LittleEndian.Uint64(idx[1:])
+		entry := binary.LittleEndian.Uint64(idx[9:])
+
+		if entry != uint64(i*3) {
+			t.Fatalf("Expected checkpoint for entry %d but got %d", i*3, entry)
+		}
+
+		// the offset should point at the entry, which logged the entry number
+		if log[off] != byte(ETLogEntry) || log[off+5] != byte(entry) {
+			t.Fatalf("Expected offset %d to point at entry %d but found % X", off, entry, log[off:off+6])
+		}
+
+		idx = idx[25:]
+	}
+}
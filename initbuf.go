@@ -0,0 +1,197 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nanolog
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// InitOverflow says what happens to the log once what is logged before
+// SetWriter goes over the limit given to WithInitLimit
+type InitOverflow int
+
+const (
+	// InitDropOldest drops the oldest entries to make room for new ones. The
+	// log line records are always kept, so the entries that are left can be
+	// decoded.
+	InitDropOldest InitOverflow = iota
+	// InitSpill moves the log to a temporary file and keeps writing it there
+	// until SetWriter copies it to the writer and removes the file
+	InitSpill
+)
+
+// InitStats describes what was done to keep what is logged before SetWriter
+// within the limit given to WithInitLimit
+type InitStats struct {
+	// Dropped is the number of entries dropped by InitDropOldest and
+	// DroppedBytes is their size
+	Dropped      uint64
+	DroppedBytes uint64
+	// Spilled is whether InitSpill moved the log to a temporary file
+	Spilled bool
+}
+
+// WithInitLimit limits how much is kept for the writer that SetWriter hasn't
+// been given yet. Until then the log is buffered in memory, and a program that
+// logs a lot before SetWriter, or never calls it, keeps all of it. With
+// InitDropOldest the entries are kept to limit bytes, not counting the log line
+// records, and the oldest ones are dropped to make room. The entries that are
+// left are written after the log line records, and the index has no checkpoints
+// for them. With InitSpill the log is moved to a temporary file once it is over
+// limit bytes. A limit of 0 turns the limit off. The option does nothing after
+// SetWriter.
+func WithInitLimit(limit int, overflow InitOverflow) Option {
+	return func(lw *logWriter) {
+		if !lw.firstSet {
+			return
+		}
+
+		// the entries held back so far go ahead of anything logged from now on
+		lw.releaseInitEntries()
+
+		lw.initLimit = limit
+		lw.initOverflow = overflow
+
+		if limit > 0 && overflow == InitDropOldest {
+			lw.initEntries = newRing(make([]byte, RingHeaderLen+limit), 0, limit)
+		}
+	}
+}
+
+// GetInitStats calls LogWriter.InitStats on the default log writer.
+func GetInitStats() InitStats {
+	return defaultLogWriter.InitStats()
+}
+
+func (lw *logWriter) InitStats() InitStats {
+	lw.writeLock.Lock()
+	defer lw.writeLock.Unlock()
+
+	return lw.initStats
+}
+
+// holdInitEntry keeps an entry logged before SetWriter in the ring of held back
+// entries, dropping the oldest ones to make room. The offset and the number of
+// entries are kept to what will be written out. The write lock must be held.
+func (lw *logWriter) holdInitEntry(b []byte) error {
+	r := lw.initEntries
+	dropped, droppedBytes := r.dropped, r.droppedBytes
+
+	if _, err := r.Write(b); err != nil {
+		// too large to keep at all, and the caller counts it once this returns
+		lw.initStats.Dropped++
+		lw.initStats.DroppedBytes += uint64(len(b))
+		lw.entries--
+		return nil
+	}
+
+	dropped = r.dropped - dropped
+	droppedBytes = r.droppedBytes - droppedBytes
+
+	lw.initStats.Dropped += dropped
+	lw.initStats.DroppedBytes += droppedBytes
+	lw.entries -= dropped
+	lw.offset += int64(len(b)) - int64(droppedBytes)
+
+	return nil
+}
+
+// releaseInitEntries writes the entries held back by InitDropOldest to the
+// buffer, after everything that was written before them. The write lock must
+// be held.
+func (lw *logWriter) releaseInitEntries() error {
+	r := lw.initEntries
+	if r == nil {
+		return nil
+	}
+	lw.initEntries = nil
+
+	var err error
+	r.drain(func(rec []byte) {
+		if err == nil {
+			_, err = lw.w.Write(rec)
+			if lw.sum != nil {
+				lw.sum.Write(rec)
+			}
+		}
+	})
+
+	return err
+}
+
+// checkInitLimit moves the log to a temporary file if it is due to be spilled.
+// The write lock must be held.
+func (lw *logWriter) checkInitLimit() error {
+	if !lw.firstSet || lw.initLimit <= 0 || lw.initOverflow != InitSpill || lw.initSpill != nil {
+		return nil
+	}
+	if lw.initBuf.Len()+lw.w.Buffered() <= lw.initLimit {
+		return nil
+	}
+
+	if err := lw.w.Flush(); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile("", "nanolog-")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(lw.initBuf.Bytes()); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	lw.initBuf.Reset()
+	lw.w.Reset(f)
+	lw.initSpill = f
+	lw.initStats.Spilled = true
+
+	return nil
+}
+
+// writeInit writes everything logged before SetWriter to the writer it was
+// given. The write lock must be held.
+func (lw *logWriter) writeInit() error {
+	if f := lw.initSpill; f != nil {
+		defer lw.removeSpill()
+
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.Copy(lw.w, f); err != nil {
+			return err
+		}
+	}
+
+	if _, err := lw.initBuf.WriteTo(lw.w); err != nil {
+		return err
+	}
+
+	return lw.releaseInitEntries()
+}
+
+// removeSpill closes and removes the temporary file the log was spilled to, if
+// there is one. The write lock must be held.
+func (lw *logWriter) removeSpill() {
+	if f := lw.initSpill; f != nil {
+		lw.initSpill = nil
+		f.Close()
+		os.Remove(f.Name())
+	}
+}
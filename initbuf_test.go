@@ -0,0 +1,145 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nanolog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"testing"
+)
+
+func TestInitDropOldest(t *testing.T) {
+	// room for 3 entries of 6 bytes and their lengths
+	lw := New(WithInitLimit(3*10, InitDropOldest), WithTrailer(true))
+
+	h1 := lw.AddLogger("first %u8")
+	for i := 0; i < 4; i++ {
+		lw.Log(h1, uint8(i))
+	}
+	// a log line added after entries were dropped is still kept
+	h2 := lw.AddLogger("second %u8")
+	lw.Log(h2, uint8(4))
+
+	if s := lw.InitStats(); s.Dropped != 2 || s.DroppedBytes != 12 || s.Spilled {
+		t.Fatalf("Expected 2 entries of 6 bytes to be dropped but got %+v", s)
+	}
+
+	buf := &bytes.Buffer{}
+	lw.SetWriter(buf)
+	lw.Close()
+
+	var expected []byte
+	expected = append(expected, logLineRecord(0, parseLogLine("first %u8"))...)
+	expected = append(expected, logLineRecord(1, parseLogLine("second %u8"))...)
+	expected = append(expected, u8Entry(h1, 2)...)
+	expected = append(expected, u8Entry(h1, 3)...)
+	expected = append(expected, u8Entry(h2, 4)...)
+
+	out := buf.Bytes()
+	if len(out) != len(expected)+TrailerLen || !bytes.Equal(out[:len(expected)], expected) {
+		t.Fatalf("Expected the log lines and the last 3 entries.\nExpected: % X\nGot: % X", expected, out)
+	}
+
+	tr := out[len(expected):]
+	if lines := binary.LittleEndian.Uint64(tr[2:]); lines != 2 {
+		t.Fatalf("Expected 2 log lines but got %d", lines)
+	}
+	if entries := binary.LittleEndian.Uint64(tr[10:]); entries != 3 {
+		t.Fatalf("Expected 3 entries but got %d", entries)
+	}
+	if size := binary.LittleEndian.Uint64(tr[18:]); size != uint64(len(expected)) {
+		t.Fatalf("Expected a size of %d but got %d", len(expected), size)
+	}
+	if sum := binary.LittleEndian.Uint32(tr[26:]); sum != crc32.ChecksumIEEE(expected) {
+		t.Fatalf("Expected checksum %08x but got %08x", crc32.ChecksumIEEE(expected), sum)
+	}
+}
+
+func TestInitDropOldestOff(t *testing.T) {
+	lw := New(WithInitLimit(3*10, InitDropOldest))
+
+	h := lw.AddLogger("first %u8")
+	lw.Log(h, uint8(1))
+
+	// the held back entry stays ahead of the ones logged after
+	WithInitLimit(0, InitDropOldest)(lw.(*logWriter))
+	lw.Log(h, uint8(2))
+
+	buf := &bytes.Buffer{}
+	lw.SetWriter(buf)
+	lw.Flush()
+
+	var expected []byte
+	expected = append(expected, logLineRecord(0, parseLogLine("first %u8"))...)
+	expected = append(expected, u8Entry(h, 1)...)
+	expected = append(expected, u8Entry(h, 2)...)
+
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Fatalf("Expected the entries in order.\nExpected: % X\nGot: % X", expected, buf.Bytes())
+	}
+}
+
+func TestInitSpill(t *testing.T) {
+	lw := New(WithInitLimit(64, InitSpill))
+
+	h := lw.AddLogger("spill %u8")
+	for i := 0; i < 100; i++ {
+		lw.Log(h, uint8(i))
+	}
+	lw.Flush()
+
+	f := lw.(*logWriter).initSpill
+	if f == nil || !lw.InitStats().Spilled {
+		t.Fatal("Expected the log to be spilled to a file")
+	}
+	if lw.(*logWriter).initBuf.Len() != 0 {
+		t.Fatal("Expected nothing to be left in memory")
+	}
+
+	buf := &bytes.Buffer{}
+	lw.SetWriter(buf)
+	lw.Flush()
+
+	expected := logLineRecord(0, parseLogLine("spill %u8"))
+	for i := 0; i < 100; i++ {
+		expected = append(expected, u8Entry(h, uint8(i))...)
+	}
+
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Fatalf("Expected the whole log.\nExpected: % X\nGot: % X", expected, buf.Bytes())
+	}
+	if _, err := os.Stat(f.Name()); !os.IsNotExist(err) {
+		t.Fatalf("Expected the spill file to be removed but got %v", err)
+	}
+}
+
+func TestInitSpillClose(t *testing.T) {
+	lw := New(WithInitLimit(1, InitSpill))
+
+	h := lw.AddLogger("spill %u8")
+	lw.Log(h, uint8(1))
+
+	f := lw.(*logWriter).initSpill
+	if f == nil {
+		t.Fatal("Expected the log to be spilled to a file")
+	}
+
+	lw.Close()
+	if _, err := os.Stat(f.Name()); !os.IsNotExist(err) {
+		t.Fatalf("Expected Close to remove the spill file but got %v", err)
+	}
+}
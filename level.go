@@ -0,0 +1,78 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nanolog
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Level is the severity of a log line. It belongs to the log line, not to each
+// entry, so it costs nothing when logging. Log lines are at LevelInfo unless
+// they are added with AddLeveledLogger.
+type Level int8
+
+const (
+	// LevelDebug is for detail that is only needed to track down a problem
+	LevelDebug Level = iota - 1
+
+	// LevelInfo is the level of log lines added with AddLogger
+	LevelInfo
+
+	// LevelWarn is for something unexpected that was handled
+	LevelWarn
+
+	// LevelError is for something that failed
+	LevelError
+
+	// LevelFatal is for something the program can't continue after
+	LevelFatal
+)
+
+var levelNames = map[Level]string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+	LevelFatal: "fatal",
+}
+
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("level(%d)", int8(l))
+}
+
+// ParseLevel returns the level with the name given by Level.String, in any case
+func ParseLevel(name string) (Level, error) {
+	for l, n := range levelNames {
+		if strings.EqualFold(n, name) {
+			return l, nil
+		}
+	}
+	return 0, fmt.Errorf("Unknown level %q", name)
+}
+
+// AddLeveledLogger calls LogWriter.AddLeveledLogger on the default log writer.
+func AddLeveledLogger(level Level, fmt string) Handle {
+	return defaultLogWriter.addLogger("", level, fmt)
+}
+
+// AddLeveledLogger adds a log line like AddLogger at the given level. The level
+// is written to the log line record, unless it is LevelInfo.
+func (lw *logWriter) AddLeveledLogger(level Level, fmt string) Handle {
+	return lw.addLogger("", level, fmt)
+}
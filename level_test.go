@@ -0,0 +1,61 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nanolog
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	for _, l := range []Level{LevelDebug, LevelInfo, LevelWarn, LevelError, LevelFatal} {
+		if parsed, err := ParseLevel(l.String()); err != nil || parsed != l {
+			t.Fatalf("Expected %v but got %v, %v", l, parsed, err)
+		}
+	}
+
+	if l, err := ParseLevel("ERROR"); err != nil || l != LevelError {
+		t.Fatalf("Expected the level name to be case insensitive but got %v, %v", l, err)
+	}
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Fatal("Expected an error for an unknown level")
+	}
+}
+
+func TestAddLeveledLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	lw := New(WithDedupe(true))
+	lw.SetWriter(buf)
+
+	hInfo := lw.AddLogger("leveled")
+	hErr := lw.AddLeveledLogger(LevelError, "leveled")
+	lw.Flush()
+
+	if hInfo == hErr {
+		t.Fatal("Expected the same format at another level to be a new log line")
+	}
+
+	l := parseLogLine("leveled")
+	info := logLineRecord(0, l)
+	l.Level = LevelError
+	errRec := logLineRecord(1, l)
+
+	if !bytes.Equal(buf.Bytes(), append(info, errRec...)) {
+		t.Fatalf("Expected the level only on the error log line.\nExpected: % X\nGot: % X", append(info, errRec...), buf.Bytes())
+	}
+	if len(errRec) != len(info)+4+1+4+1 {
+		t.Fatalf("Expected the level to take one attribute but got % X", errRec)
+	}
+}
@@ -25,6 +25,13 @@
 // parser may complain saying that it encountered an invalid code. To fix this,
 // use curly braces after the percent sign to surround the code: "%{i}1 ".
 //
+// Inside curly braces the code can be followed by a colon and a presentation
+// modifier, which is used by the reader when inflating: "%{u32:x}",
+// "%{f64:.3}" or "%{i:08}". A modifier is made of the flags, width, precision
+// and verb of a fmt format, minus the percent sign. If the verb is left out it
+// is v, except for floats and complex numbers with a precision, which use f.
+// Modifiers don't change the data written for each entry.
+//
 // Kinds and their corresponding format codes:
 //
 //  Kind          | Code
@@ -67,11 +74,27 @@
 //
 //  - type:             1 byte - ETLogLine (1)
 //  - id:               4 bytes - little endian uint32
-//  - # of string segs: 4 bytes - little endian uint32
+//  - # of string segs: 4 bytes - little endian uint32, the high bit flags attributes
 //  - kinds:            (#segs - 1) bytes, each being a reflect.Kind
 //  - segments:
 //    - string length:  4 bytes - little endian uint32
 //    - string data:    ^length bytes
+//  - attributes:       only present if flagged in the # of string segs
+//    - # of attributes: 4 bytes - little endian uint32
+//    - attribute:
+//      - key:          1 byte - a LineAttr
+//      - data length:  4 bytes - little endian uint32
+//      - data:         ^length bytes
+//
+// The attributes hold optional information about the log line. The data for
+// each LineAttr is:
+//
+//  - LAModifier (1): 4 bytes - little endian uint32 index of the kind, then the modifier string
+//  - LASource (2):   4 bytes - little endian uint32 line, 4 bytes - little endian uint32
+//                    length of the file name, the file name, then the function name
+//  - LAName (3):     the name string
+//  - LALevel (4):    1 byte - the Level as a signed int8, only present for levels
+//                    other than LevelInfo
 //
 // The log entry records are formatted as follows:
 //
@@ -79,6 +102,14 @@
 //  - line id: 4 bytes - little endian uint32
 //  - data+:   var bytes - all the corresponding data for the kinds in the log line entry
 //
+// When timestamps are turned on (see WithTimestamps) the log entry records are
+// instead formatted as follows:
+//
+//  - type:      1 byte - ETTimedLogEntry (3)
+//  - line id:   4 bytes - little endian uint32
+//  - timestamp: 8 bytes - nanoseconds since the Unix epoch as little endian int64
+//  - data+:     var bytes - same as above
+//
 // The data is serialized as follows:
 //
 //  - Bool: 1 byte
@@ -121,17 +152,23 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
+	"hash"
 	"io"
 	"math"
+	"os"
 	"reflect"
+	"runtime"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 	"unicode/utf8"
 )
 
-// MaxLoggers is the maximum number of different loggers that are allowed
+// MaxLoggers is the default maximum number of different loggers that are
+// allowed. It can be changed with WithMaxLoggers.
 const MaxLoggers = 10240
 
 // Handle is a simple handle to an internal logging data structure
@@ -152,17 +189,72 @@ const (
 
 	// ETLogEntry means the log data for a single call to Log is ahead
 	ETLogEntry
+
+	// ETTimedLogEntry means the log data for a single call to Log is ahead,
+	// preceded by the time the entry was written
+	ETTimedLogEntry
+
+	// ETIndex means a checkpoint of the position in the log is ahead. These
+	// are only written to index files (see WithIndex).
+	ETIndex
+
+	// ETDictionary means the hash of a dictionary of log lines that were left
+	// out of the log is ahead (see WithDictionary)
+	ETDictionary
+
+	// ETTrailer means the summary of the log written by Close is ahead (see
+	// WithTrailer)
+	ETTrailer
+)
+
+// LineAttr is an enum that represents the keys of the optional attributes at the
+// end of a log line record
+type LineAttr byte
+
+const (
+	// LAInvalid is an invalid LineAttr
+	LAInvalid LineAttr = iota
+
+	// LAModifier is the presentation modifier of one of the kinds
+	LAModifier
+
+	// LASource is where in the source the log line was added
+	LASource
+
+	// LAName is the name of the log line
+	LAName
+
+	// LALevel is the level of the log line, as a single byte
+	LALevel
 )
 
+// SegsHasAttrs is the flag set in the number of segments of a log line record
+// when the record ends with attributes
+const SegsHasAttrs = 1 << 31
+
 // Logger is the internal struct representing the runtime state of the loggers.
-// The Segs field is not used during logging; it is only used in the inflate
-// utility but is kept during execution in case it is needed for debugging
+// The Segs and Mods fields are not used during logging; they are only used in
+// the inflate utility but are kept during execution in case they are needed for
+// debugging
 type Logger struct {
 	Kinds []reflect.Kind
 	Segs  []string
+	// Mods holds the presentation modifier for each kind, or "" where there
+	// isn't one. It is nil if the format string has no modifiers at all.
+	Mods []string
+	// Name is the name given to AddNamedLogger, if any
+	Name string
+	// Level is the level given to AddLeveledLogger, LevelInfo otherwise
+	Level Level
+	// File, Line and Func are where AddLogger was called. They are only
+	// written to the log if source locations are turned on (see
+	// WithSourceLocations).
+	File string
+	Line int
+	Func string
 }
 
-var defaultLogWriter = New()
+var defaultLogWriter = newLogWriter()
 
 type LogWriter interface {
 	// SetWriter will set up efficient writing for the log to the output stream given.
@@ -171,12 +263,42 @@ type LogWriter interface {
 	SetWriter(new io.Writer) error
 	// Flush ensures all log entries written up to this point are written to the underlying io.Writer
 	Flush() error
+	// Sync flushes the log and commits it to stable storage, if the writer is
+	// a Syncer
+	Sync() error
+	// SyncStats describes the syncs done so far
+	SyncStats() SyncStats
+	// InitStats describes what was done to keep the log within the limit
+	// given to WithInitLimit before SetWriter
+	InitStats() InitStats
+	// DumpFlightRecorder writes the entries kept in memory by the flight
+	// recorder to the log
+	DumpFlightRecorder() error
 	// AddLogger initializes a logger and returns a handle for future logging
 	AddLogger(fmt string) Handle
-	// Log logs to the output stream
+	// AddNamedLogger is AddLogger for a log line with a unique name
+	AddNamedLogger(name, fmt string) Handle
+	// AddLeveledLogger is AddLogger for a log line at a level other than
+	// LevelInfo
+	AddLeveledLogger(level Level, fmt string) Handle
+	// Lookup returns the handle of the first log line added with the format
+	Lookup(fmt string) (Handle, bool)
+	// LookupName returns the handle of the log line with the name
+	LookupName(name string) (Handle, bool)
+	// Log logs to the output stream. It returns ErrUnknownHandle or
+	// ErrForeignHandle for a handle it can tell isn't one of its own.
 	Log(handle Handle, args ...interface{}) error
-	// Debug dump of information about a handle
+	// Schema describes all of the log lines added so far, in handle order
+	Schema() []LineSchema
+	// WriteDictionary writes the log line records added so far to w, for use
+	// with WithDictionary
+	WriteDictionary(w io.Writer) error
+	// DebugDump returns the description of a handle as JSON, or the text of
+	// the error Log would return for it
 	DebugDump(handle Handle) string
+	// Close flushes the log, writes the trailer if there is one and closes the
+	// writer. Logging after Close returns ErrClosed.
+	Close() error
 }
 
 type logWriter struct {
@@ -186,20 +308,151 @@ type logWriter struct {
 
 	writeLock sync.Locker
 
-	loggers       []Logger
-	curLoggersIdx *uint32
+	reg registry
+
+	// tag is the identity of the writer carried by handles in debug mode, and
+	// debugTag is the same while debug mode is on and 0 otherwise. Handles for
+	// log lines from checkFrom on must carry the tag, which is every log line
+	// added since debug mode was turned on, and none while it is off. Both are
+	// read atomically since they can be changed by Configure.
+	tag       uint32
+	debugTag  uint32
+	checkFrom uint32
+
+	// regLock guards adding log lines and the lookups below
+	regLock  sync.Mutex
+	byFormat map[string]Handle
+	byName   map[string]Handle
+	dedupe   bool
+
+	timestamps bool
+
+	// total bytes and entries written so far
+	offset  int64
+	entries uint64
+
+	index      *bufio.Writer
+	indexEvery uint64
+
+	// log lines that are left out of the log
+	dict *Dictionary
+
+	sourceLocations bool
+
+	// the writers given to SetWriter and WithIndex, closed by Close
+	out      io.Writer
+	indexOut io.Writer
+	closed   bool
+
+	// number of log line records written so far
+	lines uint64
+	// whether Close writes a trailer, and the checksum of everything written
+	// if it can have one
+	trailer bool
+	sum     hash.Hash32
+
+	// entries for log lines at this level or above are flushed right away
+	flushLevel Level
+	// closed to stop the background flushing, if there is any
+	flushStop chan struct{}
+
+	syncPolicy SyncPolicy
+	syncStats  SyncStats
+	// closed to stop the background syncing, if there is any
+	syncStop chan struct{}
+
+	// written to instead of w, see WithRing
+	ring *Ring
+	// number and size of the entries overwritten in the ring, which the
+	// trailer leaves out
+	ringDropped      uint64
+	ringDroppedBytes int64
+	// log lines whose records didn't fit in the log line area of the ring,
+	// which Log returns ErrRingLinesFull for
+	ringLinesFull map[uint32]bool
+
+	// keeps the entries for log lines below recordBelow until one at dumpAt
+	// comes along, see WithFlightRecorder
+	recorder    *Ring
+	recordBelow Level
+	dumpAt      Level
+
+	// what is logged before SetWriter is kept to initLimit bytes, see
+	// WithInitLimit. The entries are held back in initEntries by
+	// InitDropOldest, and the log goes to initSpill once spilled by InitSpill.
+	initLimit    int
+	initOverflow InitOverflow
+	initEntries  *Ring
+	initSpill    *os.File
+	initStats    InitStats
+
+	// written to instead of w, see NewTee
+	tee *tee
+}
+
+// Option configures optional behavior of a LogWriter. Options are given to New
+// or, for the default log writer, to Configure.
+type Option func(*logWriter)
+
+// WithTimestamps turns on recording the time of each call to Log. Timestamped
+// entries are written as ETTimedLogEntry records, which are 8 bytes larger.
+func WithTimestamps(enabled bool) Option {
+	return func(lw *logWriter) {
+		lw.timestamps = enabled
+	}
+}
+
+// WithSourceLocations turns on writing where each log line was added, the file,
+// line and function that called AddLogger, to the log line record. Log lines
+// added before the option is applied get their location written as well as
+// long as no entries have been logged and no writer has been set.
+func WithSourceLocations(enabled bool) Option {
+	return func(lw *logWriter) {
+		lw.sourceLocations = enabled
+		lw.rewriteHeader()
+	}
 }
 
 // New creates a new LogWriter
-func New() LogWriter {
+func New(opts ...Option) LogWriter {
+	return newLogWriter(opts...)
+}
+
+func newLogWriter(opts ...Option) *logWriter {
 	initBuf := &bytes.Buffer{}
-	return &logWriter{
-		initBuf:       initBuf,
-		w:             bufio.NewWriter(initBuf),
-		firstSet:      true,
-		writeLock:     new(sync.Mutex),
-		loggers:       make([]Logger, MaxLoggers),
-		curLoggersIdx: new(uint32),
+	lw := &logWriter{
+		initBuf:    initBuf,
+		w:          bufio.NewWriter(initBuf),
+		firstSet:   true,
+		writeLock:  new(sync.Mutex),
+		reg:        registry{max: MaxLoggers},
+		byFormat:   make(map[string]Handle),
+		byName:     make(map[string]Handle),
+		tag:        nextHandleTag(),
+		checkFrom:  checkNone,
+		flushLevel: flushNever,
+	}
+
+	if debugFromEnv() {
+		lw.debugTag = lw.tag
+		lw.checkFrom = 0
+	}
+
+	for _, opt := range opts {
+		opt(lw)
+	}
+
+	return lw
+}
+
+// Configure applies the given options to the default log writer. It is meant to
+// be called during program initialization, before any logging is done.
+func Configure(opts ...Option) {
+	defaultLogWriter.writeLock.Lock()
+	defer defaultLogWriter.writeLock.Unlock()
+
+	for _, opt := range opts {
+		opt(defaultLogWriter)
 	}
 }
 
@@ -213,15 +466,26 @@ func (lw *logWriter) SetWriter(new io.Writer) error {
 	lw.writeLock.Lock()
 	defer lw.writeLock.Unlock()
 
+	if lw.closed {
+		return ErrClosed
+	}
+	if lw.ring != nil {
+		return ErrRingWriter
+	}
+	if lw.tee != nil {
+		return ErrTeeWriter
+	}
+
 	if err := lw.w.Flush(); err != nil {
 		return err
 	}
 
 	lw.w = bufio.NewWriter(new)
+	lw.out = new
 
 	if lw.firstSet {
 		lw.firstSet = false
-		if _, err := lw.initBuf.WriteTo(lw.w); err != nil {
+		if err := lw.writeInit(); err != nil {
 			return err
 		}
 	}
@@ -239,28 +503,85 @@ func (lw *logWriter) Flush() error {
 	lw.writeLock.Lock()
 	defer lw.writeLock.Unlock()
 
-	return lw.w.Flush()
+	if lw.closed {
+		return ErrClosed
+	}
+
+	return lw.flush()
+}
+
+// flush writes out everything buffered, and syncs it if the sync policy says to
+// on every flush. The write lock must be held.
+func (lw *logWriter) flush() error {
+	if lw.index != nil {
+		if err := lw.index.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if err := lw.w.Flush(); err != nil {
+		return err
+	}
+	if lw.tee != nil {
+		if err := lw.tee.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if lw.syncPolicy.OnFlush {
+		return lw.syncOut()
+	}
+
+	return nil
 }
 
 // AddLogger calls LogWriter.AddLogger on the default log writer.
 func AddLogger(fmt string) Handle {
-	return defaultLogWriter.AddLogger(fmt)
+	return defaultLogWriter.addLogger("", LevelInfo, fmt)
 }
 
 func (lw *logWriter) AddLogger(fmt string) Handle {
-	// save some kind of string format to the file
-	idx := atomic.AddUint32(lw.curLoggersIdx, 1) - 1
+	return lw.addLogger("", LevelInfo, fmt)
+}
 
-	if idx >= MaxLoggers {
-		panic("Too many loggers")
+// addLogger must be called straight from one of the exported functions that
+// add a log line, like AddLogger or AddNamedLogger, so the caller is always at
+// the same depth
+func (lw *logWriter) addLogger(name string, level Level, fmt string) Handle {
+	l := parseLogLine(fmt)
+	l.Name = name
+	l.Level = level
+
+	// skip runtime.Caller, addLogger and AddLogger
+	if pc, file, line, ok := runtime.Caller(2); ok {
+		l.File, l.Line = file, line
+		if f := runtime.FuncForPC(pc); f != nil {
+			l.Func = f.Name()
+		}
 	}
 
-	l := parseLogLine(fmt)
-	lw.loggers[idx] = l
+	return lw.addLine(l)
+}
+
+// addLine adds a parsed log line, or returns the handle of the same one added
+// before
+func (lw *logWriter) addLine(l Logger) Handle {
+	// the header is written before unlocking so nobody can get the handle
+	// from a lookup and log with it before the log line is in the log
+	lw.regLock.Lock()
+	defer lw.regLock.Unlock()
 
-	lw.writeLogLineHeader(idx, l.Kinds, l.Segs)
+	if h, ok := lw.existing(l); ok {
+		return lw.handle(h)
+	}
+
+	// save some kind of string format to the file
+	h := lw.reg.add(l)
+	lw.register(h, l)
+
+	lw.writeLogLineHeader(uint32(h), l)
 
-	return Handle(idx)
+	return lw.handle(h)
 }
 
 func parseLogLine(gold string) Logger {
@@ -269,6 +590,8 @@ func parseLogLine(gold string) Logger {
 	f := &tmp
 	var kinds []reflect.Kind
 	var segs []string
+	var mods []string
+	var hasMods bool
 	var curseg []rune
 
 	for len(*f) > 0 {
@@ -427,22 +750,126 @@ func parseLogLine(gold string) Logger {
 			logpanic("Invalid replace sequence", gold)
 		}
 
+		var mod string
+
 		if requireBrace {
 			if len(*f) == 0 {
 				logpanic("Missing '}' character at end of line", gold)
 			}
-			if next(f) != '}' {
+
+			r := next(f)
+
+			// Optional presentation modifier
+			if r == ':' {
+				end := strings.IndexByte(*f, '}')
+				if end < 0 {
+					logpanic("Missing '}' character after modifier", gold)
+				}
+
+				mod = (*f)[:end]
+				*f = (*f)[end:]
+				r = next(f)
+
+				if !validModifier(kinds[len(kinds)-1], mod) {
+					logpanic("Invalid modifier", gold)
+				}
+
+				hasMods = true
+			}
+
+			if r != '}' {
 				logpanic("Missing '}' character", gold)
 			}
 		}
+
+		mods = append(mods, mod)
 	}
 
 	segs = append(segs, string(curseg))
 
+	if !hasMods {
+		mods = nil
+	}
+
 	return Logger{
 		Kinds: kinds,
 		Segs:  segs,
+		Mods:  mods,
+	}
+}
+
+// verbs that make sense for each kind, in addition to v
+var modifierVerbs = map[reflect.Kind]string{
+	reflect.Bool:       "t",
+	reflect.Int:        "bcdoOqxXU",
+	reflect.Int8:       "bcdoOqxXU",
+	reflect.Int16:      "bcdoOqxXU",
+	reflect.Int32:      "bcdoOqxXU",
+	reflect.Int64:      "bcdoOqxXU",
+	reflect.Uint:       "bcdoOqxXU",
+	reflect.Uint8:      "bcdoOqxXU",
+	reflect.Uint16:     "bcdoOqxXU",
+	reflect.Uint32:     "bcdoOqxXU",
+	reflect.Uint64:     "bcdoOqxXU",
+	reflect.Float32:    "beEfFgGxX",
+	reflect.Float64:    "beEfFgGxX",
+	reflect.Complex64:  "beEfFgGxX",
+	reflect.Complex128: "beEfFgGxX",
+	reflect.String:     "sqxX",
+}
+
+// validModifier checks that mod is made of flags, width, precision and verb in
+// that order, each being optional, and that the verb fits the kind
+func validModifier(k reflect.Kind, mod string) bool {
+	if mod == "" {
+		return false
+	}
+
+	i := 0
+	for i < len(mod) && strings.IndexByte("+-# 0", mod[i]) >= 0 {
+		i++
+	}
+	for i < len(mod) && mod[i] >= '0' && mod[i] <= '9' {
+		i++
+	}
+	if i < len(mod) && mod[i] == '.' {
+		i++
+		for i < len(mod) && mod[i] >= '0' && mod[i] <= '9' {
+			i++
+		}
+	}
+
+	switch len(mod) - i {
+	case 0:
+		return true
+	case 1:
+		return mod[i] == 'v' || strings.IndexByte(modifierVerbs[k], mod[i]) >= 0
+	}
+
+	return false
+}
+
+// Verb returns the fmt format for the i'th kind as given by its presentation
+// modifier, e.g. "%08x", or "" if it doesn't have one.
+func (l Logger) Verb(i int) string {
+	if i >= len(l.Mods) || l.Mods[i] == "" {
+		return ""
+	}
+
+	mod := l.Mods[i]
+
+	if last := mod[len(mod)-1]; (last >= 'a' && last <= 'z') || (last >= 'A' && last <= 'Z') {
+		return "%" + mod
+	}
+
+	switch l.Kinds[i] {
+	case reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		if strings.IndexByte(mod, '.') >= 0 {
+			return "%" + mod + "f"
+		}
 	}
+
+	return "%" + mod + "v"
 }
 
 func peek(s *string) rune {
@@ -466,9 +893,148 @@ func next(s *string) rune {
 	return r
 }
 
-func (lw *logWriter) writeLogLineHeader(idx uint32, kinds []reflect.Kind, segs []string) {
+var kindCodes = map[reflect.Kind]string{
+	reflect.Bool:       "b",
+	reflect.Int:        "i",
+	reflect.Int8:       "i8",
+	reflect.Int16:      "i16",
+	reflect.Int32:      "i32",
+	reflect.Int64:      "i64",
+	reflect.Uint:       "u",
+	reflect.Uint8:      "u8",
+	reflect.Uint16:     "u16",
+	reflect.Uint32:     "u32",
+	reflect.Uint64:     "u64",
+	reflect.Float32:    "f32",
+	reflect.Float64:    "f64",
+	reflect.Complex64:  "c64",
+	reflect.Complex128: "c128",
+	reflect.String:     "s",
+}
+
+// Format rebuilds a format string that parses to the same Logger. It is not
+// necessarily identical to the string originally given to AddLogger, e.g.
+// curly braces are only used where they are required.
+func (l Logger) Format() string {
+	sb := &strings.Builder{}
+
+	for i, seg := range l.Segs {
+		sb.WriteString(strings.Replace(seg, "%", "%%", -1))
+
+		if i >= len(l.Kinds) {
+			break
+		}
+
+		code, ok := kindCodes[l.Kinds[i]]
+		if !ok {
+			code = "?"
+		}
+
+		// braces are needed if the next segment could be read as part of the code
+		next := l.Segs[i+1]
+		if i < len(l.Mods) && l.Mods[i] != "" {
+			sb.WriteString("%{" + code + ":" + l.Mods[i] + "}")
+		} else if len(next) > 0 && next[0] >= '0' && next[0] <= '9' {
+			sb.WriteString("%{" + code + "}")
+		} else {
+			sb.WriteString("%" + code)
+		}
+	}
+
+	return sb.String()
+}
+
+func (lw *logWriter) writeLogLineHeader(idx uint32, l Logger) {
+	lw.writeLock.Lock()
+	defer lw.writeLock.Unlock()
+
+	if lw.closed {
+		return
+	}
+
+	rec := lw.logLineRecord(idx, l)
+	lw.writeLogLine(idx, rec)
+
+	// and to the index so it can be read on its own
+	if lw.index != nil {
+		lw.index.Write(rec)
+	}
+}
+
+// writeLogLine writes a log line record to the output, unless the reader will
+// have it from the dictionary. A log line that doesn't fit in the ring is kept
+// out of the log. The write lock must be held.
+func (lw *logWriter) writeLogLine(idx uint32, rec []byte) {
+	delete(lw.ringLinesFull, idx)
+	if lw.dict != nil && lw.dict.has(idx, rec) {
+		return
+	}
+
+	if err := lw.write(rec); err == ErrRingLinesFull {
+		if lw.ringLinesFull == nil {
+			lw.ringLinesFull = make(map[uint32]bool)
+		}
+		lw.ringLinesFull[idx] = true
+		return
+	}
+	lw.lines++
+}
+
+// rewriteHeader writes the log line records again if they are still waiting in
+// the initial buffer with nothing else, so that options that change them also
+// apply to the log lines added before the options. It returns false if an
+// entry has been logged, a writer has been set or the log goes to a ring, whose
+// log lines can't be taken back. The write lock must be held.
+func (lw *logWriter) rewriteHeader() bool {
+	if !lw.firstSet || lw.entries != 0 || lw.ring != nil {
+		return false
+	}
+
+	lw.removeSpill()
+	lw.initBuf.Reset()
+	lw.w.Reset(lw.initBuf)
+	lw.offset = 0
+	lw.lines = 0
+	if lw.sum != nil {
+		lw.sum.Reset()
+	}
+
+	if lw.dict != nil {
+		lw.writeDictionaryRef()
+	}
+
+	lw.eachLogger(func(idx uint32, l Logger) {
+		lw.writeLogLine(idx, lw.logLineRecord(idx, l))
+	})
+
+	return true
+}
+
+// eachLogger calls f with each log line added so far, in handle order
+func (lw *logWriter) eachLogger(f func(idx uint32, l Logger)) {
+	n := lw.reg.len()
+
+	for i := uint32(0); i < n; i++ {
+		f(i, *lw.reg.get(Handle(i)))
+	}
+}
+
+// logLineRecord serializes the log line record for a logger as this writer
+// writes it
+func (lw *logWriter) logLineRecord(idx uint32, l Logger) []byte {
+	if !lw.sourceLocations {
+		l.File, l.Line, l.Func = "", 0, ""
+	}
+
+	return logLineRecord(idx, l)
+}
+
+// logLineRecord serializes the log line record for a logger
+func logLineRecord(idx uint32, l Logger) []byte {
 	buf := &bytes.Buffer{}
 	b := make([]byte, 4)
+	kinds, segs := l.Kinds, l.Segs
+	attrs := lineAttrs(l)
 
 	// write log line record identifier
 	buf.WriteByte(byte(ETLogLine))
@@ -480,11 +1046,15 @@ func (lw *logWriter) writeLogLineHeader(idx uint32, kinds []reflect.Kind, segs [
 	// write number of string segments between variable parts
 	// we don't need to write the number of kinds here because it is always
 	// equal to the number of segments minus 1
-	if len(segs) > math.MaxUint32 {
+	if len(segs) >= SegsHasAttrs {
 		// what the hell are you logging?!
 		panic("Too many log line segments")
 	}
-	binary.LittleEndian.PutUint32(b, uint32(len(segs)))
+	numsegs := uint32(len(segs))
+	if len(attrs) > 0 {
+		numsegs |= SegsHasAttrs
+	}
+	binary.LittleEndian.PutUint32(b, numsegs)
 	buf.Write(b)
 
 	// write out all the kinds. These are cast to a byte because their values all
@@ -500,8 +1070,87 @@ func (lw *logWriter) writeLogLineHeader(idx uint32, kinds []reflect.Kind, segs [
 		buf.WriteString(s)
 	}
 
-	// finally write all of it together to the output
-	lw.w.Write(buf.Bytes())
+	// write the optional attributes, count first then key, length and data for each
+	if len(attrs) > 0 {
+		binary.LittleEndian.PutUint32(b, uint32(len(attrs)))
+		buf.Write(b)
+
+		for _, a := range attrs {
+			buf.WriteByte(byte(a.key))
+			binary.LittleEndian.PutUint32(b, uint32(len(a.data)))
+			buf.Write(b)
+			buf.Write(a.data)
+		}
+	}
+
+	return buf.Bytes()
+}
+
+// write writes a whole record to the output. The write lock must be held.
+func (lw *logWriter) write(b []byte) error {
+	var n int
+	var err error
+	switch {
+	case lw.ring != nil:
+		n, err = lw.writeRing(b)
+	case lw.initEntries != nil && !isLineRecord(b):
+		return lw.holdInitEntry(b)
+	case lw.tee != nil:
+		n, err = lw.writeTee(b)
+	default:
+		n, err = lw.w.Write(b)
+	}
+
+	lw.offset += int64(n)
+	if lw.sum != nil {
+		lw.sum.Write(b[:n])
+	}
+	if err == nil {
+		err = lw.checkInitLimit()
+	}
+	return err
+}
+
+type lineAttr struct {
+	key  LineAttr
+	data []byte
+}
+
+// lineAttrs collects the attributes to write at the end of the log line record
+func lineAttrs(l Logger) []lineAttr {
+	var attrs []lineAttr
+
+	for i, mod := range l.Mods {
+		if mod == "" {
+			continue
+		}
+
+		data := make([]byte, 4, 4+len(mod))
+		binary.LittleEndian.PutUint32(data, uint32(i))
+		data = append(data, mod...)
+
+		attrs = append(attrs, lineAttr{key: LAModifier, data: data})
+	}
+
+	if l.Name != "" {
+		attrs = append(attrs, lineAttr{key: LAName, data: []byte(l.Name)})
+	}
+
+	if l.Level != LevelInfo {
+		attrs = append(attrs, lineAttr{key: LALevel, data: []byte{byte(l.Level)}})
+	}
+
+	if l.File != "" {
+		data := make([]byte, 8, 8+len(l.File)+len(l.Func))
+		binary.LittleEndian.PutUint32(data, uint32(l.Line))
+		binary.LittleEndian.PutUint32(data[4:], uint32(len(l.File)))
+		data = append(data, l.File...)
+		data = append(data, l.Func...)
+
+		attrs = append(attrs, lineAttr{key: LASource, data: data})
+	}
+
+	return attrs
 }
 
 // helper function to have consistently formatted panics and shorter code above
@@ -524,7 +1173,13 @@ func Log(handle Handle, args ...interface{}) error {
 }
 
 func (lw *logWriter) Log(handle Handle, args ...interface{}) error {
-	l := lw.loggers[handle]
+	l := lw.reg.get(handle)
+	if l == nil || uint32(handle) >= atomic.LoadUint32(&lw.checkFrom) {
+		var err error
+		if handle, l, err = lw.resolve(handle); err != nil {
+			return err
+		}
+	}
 
 	if len(l.Kinds) != len(args) {
 		panic("Number of args does not match log line")
@@ -534,11 +1189,21 @@ func (lw *logWriter) Log(handle Handle, args ...interface{}) error {
 	*buf = (*buf)[:0]
 	b := make([]byte, 8)
 
-	*buf = append(*buf, byte(ETLogEntry))
+	if lw.timestamps {
+		*buf = append(*buf, byte(ETTimedLogEntry))
+	} else {
+		*buf = append(*buf, byte(ETLogEntry))
+	}
 
 	binary.LittleEndian.PutUint32(b, uint32(handle))
 	*buf = append(*buf, b[:4]...)
 
+	// space for the timestamp, which is filled in once the write lock is held
+	// so the entries in the output are in time order
+	if lw.timestamps {
+		*buf = append(*buf, 0, 0, 0, 0, 0, 0, 0, 0)
+	}
+
 	for idx := range l.Kinds {
 		if l.Kinds[idx] != reflect.TypeOf(args[idx]).Kind() {
 			panic("Argument type does not match log line")
@@ -657,7 +1322,49 @@ func (lw *logWriter) Log(handle Handle, args ...interface{}) error {
 	}
 
 	lw.writeLock.Lock()
-	_, err := lw.w.Write(*buf)
+	if lw.closed {
+		lw.writeLock.Unlock()
+		bufpool.Put(buf)
+		return ErrClosed
+	}
+	if lw.ringLinesFull[uint32(handle)] {
+		lw.writeLock.Unlock()
+		bufpool.Put(buf)
+		return ErrRingLinesFull
+	}
+
+	var now time.Time
+	if (*buf)[0] == byte(ETTimedLogEntry) {
+		now = time.Now()
+		binary.LittleEndian.PutUint64((*buf)[5:13], uint64(now.UnixNano()))
+	}
+	if lw.recorder != nil {
+		if l.Level < lw.recordBelow {
+			// an entry too big for the recorder is lost either way
+			lw.recorder.Write(*buf)
+			lw.writeLock.Unlock()
+			bufpool.Put(buf)
+			return nil
+		}
+		if l.Level >= lw.dumpAt {
+			if err := lw.dumpRecorder(); err != nil {
+				lw.writeLock.Unlock()
+				bufpool.Put(buf)
+				return err
+			}
+		}
+	}
+	if lw.index != nil && lw.initEntries == nil {
+		lw.checkpoint(now)
+	}
+	err := lw.write(*buf)
+	lw.entries++
+	if l.Level >= lw.flushLevel && err == nil {
+		err = lw.flush()
+	}
+	if lw.syncPolicy.Entries > 0 && lw.entries%lw.syncPolicy.Entries == 0 && err == nil {
+		err = lw.sync()
+	}
 	lw.writeLock.Unlock()
 
 	bufpool.Put(buf)
@@ -670,23 +1377,18 @@ func DebugDump(handle Handle) string {
 }
 
 func (lw *logWriter) DebugDump(handle Handle) string {
-	sb := &strings.Builder{}
-	l := lw.loggers[handle]
-
-	for i := 0; i < len(l.Kinds); i++ {
-		sb.WriteString("+\"")
-		sb.WriteString(l.Segs[i])
-		sb.WriteString("\"+")
-
-		sb.WriteString("<")
-		sb.WriteString(l.Kinds[i].String())
-		sb.WriteString(">")
+	l := lw.reg.get(handle)
+	if l == nil || uint32(handle) >= atomic.LoadUint32(&lw.checkFrom) {
+		var err error
+		if handle, l, err = lw.resolve(handle); err != nil {
+			return err.Error()
+		}
 	}
 
-	// write the last segment
-	sb.WriteString("+\"")
-	sb.WriteString(l.Segs[len(l.Segs)-1])
-	sb.WriteString("\"+")
+	b, err := json.Marshal(NewLineSchema(handle, *l))
+	if err != nil {
+		return err.Error()
+	}
 
-	return sb.String()
+	return string(b)
 }
@@ -22,10 +22,12 @@ import (
 	"math"
 	"math/rand"
 	"reflect"
+	"runtime"
 	"strconv"
 	"strings"
 	"testing"
 	"testing/quick"
+	"time"
 )
 
 var testLetters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
@@ -303,6 +305,11 @@ func TestParseLogLine(t *testing.T) {
 
 		tests["CorrectButMissingEndBrace"] = "%{b"
 		tests["CorrectButMissingEndBraceNotAtEnd"] = "%{bX"
+		tests["ModifierMissingEndBrace"] = "%{u32:x"
+		tests["ModifierEmpty"] = "%{u32:}"
+		tests["ModifierBadVerb"] = "%{u32:f}"
+		tests["ModifierTwoVerbs"] = "%{f64:.3ff}"
+		tests["ModifierOutOfOrder"] = "%{i:.3-5}"
 
 		for name, fmt := range tests {
 			t.Run(name, func(t *testing.T) { check(t, fmt) })
@@ -310,6 +317,107 @@ func TestParseLogLine(t *testing.T) {
 	})
 }
 
+func TestParseLogLineModifiers(t *testing.T) {
+	l := parseLogLine("%{u32:x} %s %{f64:.3} %{i:08} %{s:q} %{c64:+.2}")
+
+	expMods := []string{"x", "", ".3", "08", "q", "+.2"}
+	if !reflect.DeepEqual(l.Mods, expMods) {
+		t.Fatalf("Expected mods %q but got %q", expMods, l.Mods)
+	}
+
+	expVerbs := []string{"%x", "", "%.3f", "%08v", "%q", "%+.2f"}
+	for i, exp := range expVerbs {
+		if v := l.Verb(i); v != exp {
+			t.Fatalf("Expected verb %q for kind %d but got %q", exp, i, v)
+		}
+	}
+
+	if l := parseLogLine("%{u32} %s"); l.Mods != nil {
+		t.Fatalf("Expected no mods but got %q", l.Mods)
+	}
+}
+
+func TestAddLoggerModifiers(t *testing.T) {
+	buf := &bytes.Buffer{}
+	lw := New()
+	lw.SetWriter(buf)
+	lw.AddLogger("%{u8:x}")
+	lw.Flush()
+
+	out := buf.Bytes()
+
+	// header, kind and two empty segments, then the attributes
+	attrs := out[1+4+4+1+4+4:]
+
+	if numsegs := binary.LittleEndian.Uint32(out[5:]); numsegs != 2|SegsHasAttrs {
+		t.Fatalf("Expected the number of segments to flag attributes but got %X", numsegs)
+	}
+
+	expected := []byte{1, 0, 0, 0, byte(LAModifier), 5, 0, 0, 0, 0, 0, 0, 0, 'x'}
+	if !bytes.Equal(attrs, expected) {
+		t.Fatalf("Expected attributes % X but got % X", expected, attrs)
+	}
+}
+
+func TestAddLoggerSourceLocations(t *testing.T) {
+	buf := &bytes.Buffer{}
+	lw := New()
+	_, file, line, _ := runtime.Caller(0)
+	// added before the option is applied
+	lw.AddLogger("%u8")
+	WithSourceLocations(true)(lw.(*logWriter))
+	lw.AddLogger("")
+	lw.SetWriter(buf)
+	lw.Flush()
+
+	fn := "github.com/ScottMansfield/nanolog.TestAddLoggerSourceLocations"
+
+	for i, l := range []Logger{parseLogLine("%u8"), parseLogLine("")} {
+		l.File, l.Line, l.Func = file, line+2+2*i, fn
+
+		rec := logLineRecord(uint32(i), l)
+		if !bytes.HasPrefix(buf.Bytes(), rec) {
+			t.Fatalf("Expected log line record with the location.\nExpected: % X\nGot: % X", rec, buf.Bytes())
+		}
+		buf.Next(len(rec))
+
+		attrs := lineAttrs(l)
+		if len(attrs) != 1 || attrs[0].key != LASource {
+			t.Fatalf("Expected a source attribute but got %v", attrs)
+		}
+
+		expected := make([]byte, 8)
+		binary.LittleEndian.PutUint32(expected, uint32(l.Line))
+		binary.LittleEndian.PutUint32(expected[4:], uint32(len(file)))
+		expected = append(expected, file+fn...)
+		if !bytes.Equal(attrs[0].data, expected) {
+			t.Fatalf("Expected source attribute % X but got % X", expected, attrs[0].data)
+		}
+	}
+
+	if buf.Len() != 0 {
+		t.Fatalf("Unexpected trailing data % X", buf.Bytes())
+	}
+}
+
+func TestAddLoggerNoSourceLocations(t *testing.T) {
+	buf := &bytes.Buffer{}
+	lw := New()
+	lw.SetWriter(buf)
+	h := lw.AddLogger("%u8")
+	lw.Flush()
+
+	// the location is kept in memory but not written
+	if l := *lw.(*logWriter).reg.get(h); l.File == "" || l.Line == 0 || l.Func == "" {
+		t.Fatalf("Expected the source location to be captured but got %+v", l)
+	}
+
+	expected := logLineRecord(uint32(h), parseLogLine("%u8"))
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Fatalf("Expected record without location.\nExpected: % X\nGot: % X", expected, buf.Bytes())
+	}
+}
+
 func TestLog(t *testing.T) {
 	check := func(t *testing.T, fmtstring string, toWrite interface{}, dataLen int, checkRest func(*testing.T, []byte) bool) bool {
 		// Reset to avoid running over the loggers limit
@@ -789,14 +897,12 @@ func TestLog(t *testing.T) {
 var testLogHandleSink Handle
 
 func BenchmarkAddLogger(b *testing.B) {
-	lw := New()
+	// no limit so b.N can grow past MaxLoggers
+	lw := New(WithMaxLoggers(0))
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		testLogHandleSink = lw.AddLogger("foo thing bar thing %i64. Fubar %s foo. sadfasdf %u32 sdfasfasdfasdffds %u32.")
-
-		// to prevent it from overflowing the logger array
-		*lw.(*logWriter).curLoggersIdx = 0
 	}
 }
 
@@ -917,3 +1023,54 @@ func BenchmarkInterpolations(b *testing.B) {
 		b.Run("complex128", f("%c128", complex(float64(4), float64(4)), 10))
 	})
 }
+
+func TestLoggerFormat(t *testing.T) {
+	tests := []string{
+		"",
+		"no interpolations",
+		"foo thing bar thing %i64. Fubar %s foo. sadf %% asdf %u32 sdfasfasdfasdffds %u32.",
+		"%b%s%i%i8%i16%i32%i64%u%u8%u16%u32%u64%f32%f64%c64%c128",
+		"Disambiguate this: %{i}32 %{u}8",
+		"Modifiers %{u32:x} %{f64:.3} %{i:08} %s",
+	}
+
+	for _, f := range tests {
+		l := parseLogLine(f)
+		rebuilt := parseLogLine(l.Format())
+
+		if !reflect.DeepEqual(l, rebuilt) {
+			t.Fatalf("Format of %q produced %q which parses differently", f, l.Format())
+		}
+	}
+}
+
+func TestLogTimestamps(t *testing.T) {
+	buf := &bytes.Buffer{}
+	lw := New(WithTimestamps(true))
+	lw.SetWriter(buf)
+	h := lw.AddLogger("%u8")
+	lw.Flush()
+	buf.Reset()
+
+	before := time.Now().UnixNano()
+	lw.Log(h, uint8(7))
+	after := time.Now().UnixNano()
+	lw.Flush()
+
+	out := buf.Bytes()
+
+	if len(out) != 1+4+8+1 {
+		t.Fatalf("Expected serialized length of %v but got %v.\nOutput: % X", 1+4+8+1, len(out), out)
+	}
+	if out[0] != byte(ETTimedLogEntry) {
+		t.Fatalf("Expected first byte to be ETTimedLogEntry but got %v", out[0])
+	}
+
+	ts := int64(binary.LittleEndian.Uint64(out[5:]))
+	if ts < before || ts > after {
+		t.Fatalf("Expected timestamp between %v and %v but got %v", before, after, ts)
+	}
+	if out[13] != 7 {
+		t.Fatalf("Expected data to follow the timestamp but got % X", out[13:])
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reader
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/ScottMansfield/nanolog"
+)
+
+// SetDictionary gives the Reader the dictionary a log was written with (see
+// nanolog.WithDictionary). The log lines in it are used once the log refers to
+// it, except for those the log defines itself. A log that refers to a
+// different dictionary can't be read.
+func (r *Reader) SetDictionary(d *nanolog.Dictionary) error {
+	dr := New(bytes.NewReader(d.Bytes()), ioutil.Discard)
+
+	for {
+		rawType, err := dr.readByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if nanolog.EntryType(rawType) != nanolog.ETLogLine {
+			return nanolog.ErrBadDictionary
+		}
+		if _, err := dr.readLogLine(); err != nil {
+			return nanolog.ErrBadDictionary
+		}
+	}
+
+	r.dict = d
+	r.dictLoggers = dr.loggers
+
+	return nil
+}
+
+// readDictionaryRef reads the rest of a dictionary reference and defines the
+// log lines from the dictionary
+func (r *Reader) readDictionaryRef() error {
+	hash, err := r.readUint64()
+	if err != nil {
+		return err
+	}
+
+	if r.dict == nil {
+		// not an error yet, the log may define everything it uses itself
+		r.missingDict = hash
+		return nil
+	}
+
+	if hash != r.dict.Hash() {
+		return fmt.Errorf("Log needs dictionary %016x but was given %016x", hash, r.dict.Hash())
+	}
+
+	for id, l := range r.dictLoggers {
+		if _, ok := r.loggers[id]; ok {
+			continue
+		}
+
+		r.loggers[id] = l
+
+		if r.filter != nil {
+			r.lineOK[id] = r.filter.matchLine(nanolog.Handle(id), l)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reader
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ScottMansfield/nanolog"
+)
+
+func TestDictionary(t *testing.T) {
+	dictbuf := &bytes.Buffer{}
+	lw := nanolog.New()
+	lw.AddLogger("from the dictionary %i")
+	lw.WriteDictionary(dictbuf)
+
+	d, err := nanolog.NewDictionary(dictbuf.Bytes())
+	if err != nil {
+		t.Fatalf("Got error reading dictionary: %v", err)
+	}
+
+	logbuf := &bytes.Buffer{}
+	lw = nanolog.New(nanolog.WithDictionary(d))
+	lw.SetWriter(logbuf)
+	h1 := lw.AddLogger("from the dictionary %i")
+	h2 := lw.AddLogger("from the log %s")
+	lw.Log(h1, 1)
+	lw.Log(h2, "two")
+	lw.Flush()
+
+	t.Run("WithDictionary", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		r := New(bytes.NewReader(logbuf.Bytes()), out)
+		if err := r.SetDictionary(d); err != nil {
+			t.Fatalf("Got error setting dictionary: %v", err)
+		}
+
+		if err := r.Inflate(); err != nil {
+			t.Fatalf("Got error during inflate: %v", err)
+		}
+
+		expected := "from the dictionary 1\nfrom the log two\n"
+		if out.String() != expected {
+			t.Fatalf("Expected %q but got %q", expected, out.String())
+		}
+	})
+
+	t.Run("WithoutDictionary", func(t *testing.T) {
+		r := New(bytes.NewReader(logbuf.Bytes()), &bytes.Buffer{})
+
+		_, err := r.Next()
+		if err == nil || !strings.Contains(err.Error(), "needs dictionary") {
+			t.Fatalf("Expected an error about the missing dictionary but got %v", err)
+		}
+	})
+
+	t.Run("WrongDictionary", func(t *testing.T) {
+		other, _ := nanolog.NewDictionary(nil)
+
+		r := New(bytes.NewReader(logbuf.Bytes()), &bytes.Buffer{})
+		r.SetDictionary(other)
+
+		_, err := r.Next()
+		if err == nil || !strings.Contains(err.Error(), "but was given") {
+			t.Fatalf("Expected an error about the wrong dictionary but got %v", err)
+		}
+	})
+}
@@ -0,0 +1,357 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reader
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ScottMansfield/nanolog"
+)
+
+// Filter selects which log entries a Reader returns. All of the parts that are
+// set must match for an entry to be returned.
+type Filter struct {
+	// Handles limits the entries to those logged against these handles
+	Handles []nanolog.Handle
+	// Format limits the entries to those whose log line format string, as
+	// given by Logger.Format, matches
+	Format *regexp.Regexp
+	// Args are conditions on the values of the arguments
+	Args []Predicate
+	// MinLevel, if set, limits the entries to those for log lines at the level
+	// or above
+	MinLevel *nanolog.Level
+}
+
+// SetFilter limits the entries returned from the Reader to those that match the
+// filter. Entries for log lines that are ruled out by the handles, the format or
+// the number of arguments are skipped over without being decoded. A nil filter
+// turns filtering off. The Reader keeps its own copy of the predicates, so the
+// filter can be shared, and an error is returned if one of them has a regular
+// expression that doesn't compile.
+func (r *Reader) SetFilter(f *Filter) error {
+	if f == nil {
+		r.filter = nil
+		r.lineOK = make(map[uint32]bool)
+		return nil
+	}
+
+	// compile the regular expressions of predicates not made by ParsePredicate
+	// once rather than for every entry
+	own := *f
+	own.Args = append([]Predicate(nil), f.Args...)
+	for i := range own.Args {
+		if p := &own.Args[i]; p.Op == "=~" && p.re == nil {
+			re, err := regexp.Compile(p.Value)
+			if err != nil {
+				return err
+			}
+			p.re = re
+		}
+	}
+
+	r.filter = &own
+	r.lineOK = make(map[uint32]bool)
+	for id, l := range r.loggers {
+		r.lineOK[id] = own.matchLine(nanolog.Handle(id), l)
+	}
+
+	return nil
+}
+
+// matchLine checks the parts of the filter that only depend on the log line
+func (f *Filter) matchLine(h nanolog.Handle, l nanolog.Logger) bool {
+	if len(f.Handles) > 0 {
+		found := false
+		for _, fh := range f.Handles {
+			if fh == h {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	for _, p := range f.Args {
+		if p.Arg >= len(l.Kinds) {
+			return false
+		}
+	}
+
+	if f.MinLevel != nil && l.Level < *f.MinLevel {
+		return false
+	}
+
+	if f.Format != nil && !f.Format.MatchString(l.Format()) {
+		return false
+	}
+
+	return true
+}
+
+func (f *Filter) matchArgs(rec Record) bool {
+	for _, p := range f.Args {
+		if !p.Match(rec) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Predicate is a condition on the value of a single argument of a log entry
+type Predicate struct {
+	// Arg is the index of the argument, starting at 0
+	Arg int
+	// Op is one of ==, !=, <, <=, >, >= or =~, which matches strings against
+	// a regular expression
+	Op string
+	// Value is compared to the argument according to its kind
+	Value string
+
+	re *regexp.Regexp
+}
+
+var predicateOps = []string{"==", "!=", "<=", ">=", "=~", "<", ">"}
+
+// ParsePredicate parses a condition like "arg2 > 500" or `arg0 =~ ^foo`. The
+// arguments are numbered from 0. Spaces around the operator are optional.
+func ParsePredicate(s string) (Predicate, error) {
+	s = strings.TrimSpace(s)
+
+	if !strings.HasPrefix(s, "arg") {
+		return Predicate{}, fmt.Errorf("Predicate must start with argN: %q", s)
+	}
+
+	rest := s[len("arg"):]
+	end := 0
+	for end < len(rest) && rest[end] >= '0' && rest[end] <= '9' {
+		end++
+	}
+
+	idx, err := strconv.Atoi(rest[:end])
+	if err != nil {
+		return Predicate{}, fmt.Errorf("Predicate must start with argN: %q", s)
+	}
+
+	rest = strings.TrimSpace(rest[end:])
+
+	p := Predicate{Arg: idx}
+
+	for _, op := range predicateOps {
+		if strings.HasPrefix(rest, op) {
+			p.Op = op
+			p.Value = strings.TrimSpace(rest[len(op):])
+			break
+		}
+	}
+
+	if p.Op == "" {
+		return Predicate{}, fmt.Errorf("Predicate has no valid operator: %q", s)
+	}
+
+	if p.Op == "=~" {
+		if p.re, err = regexp.Compile(p.Value); err != nil {
+			return Predicate{}, err
+		}
+	}
+
+	return p, nil
+}
+
+// Match reports whether the argument of the record satisfies the predicate.
+// Numbers are compared numerically, strings lexically and booleans and complex
+// numbers only for equality. A value that can't be compared to the argument
+// never matches. A regular expression is compiled by ParsePredicate or
+// SetFilter; otherwise Match compiles it on every call.
+func (p Predicate) Match(rec Record) bool {
+	if p.Arg < 0 || p.Arg >= len(rec.Args) {
+		return false
+	}
+
+	arg := rec.Args[p.Arg]
+
+	if p.Op == "=~" {
+		s, ok := arg.(string)
+		if !ok {
+			return false
+		}
+
+		re := p.re
+		if re == nil {
+			var err error
+			if re, err = regexp.Compile(p.Value); err != nil {
+				return false
+			}
+		}
+
+		return re.MatchString(s)
+	}
+
+	c, ok := compareArg(arg, p.Value)
+	if !ok {
+		return false
+	}
+
+	switch p.Op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	}
+
+	// only equality makes sense for these
+	switch arg.(type) {
+	case bool, complex64, complex128:
+		return false
+	}
+
+	switch p.Op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+
+	return false
+}
+
+// compareArg compares the argument to the value parsed according to the type
+// of the argument, returning -1, 0 or 1. ok is false if the value can't be
+// parsed as something comparable.
+func compareArg(arg interface{}, value string) (c int, ok bool) {
+	switch v := arg.(type) {
+	case bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return 0, false
+		}
+		if v == b {
+			return 0, true
+		}
+		return 1, true
+
+	case string:
+		return strings.Compare(v, value), true
+
+	case int:
+		return compareInt(int64(v), value)
+	case int8:
+		return compareInt(int64(v), value)
+	case int16:
+		return compareInt(int64(v), value)
+	case int32:
+		return compareInt(int64(v), value)
+	case int64:
+		return compareInt(v, value)
+
+	case uint:
+		return compareUint(uint64(v), value)
+	case uint8:
+		return compareUint(uint64(v), value)
+	case uint16:
+		return compareUint(uint64(v), value)
+	case uint32:
+		return compareUint(uint64(v), value)
+	case uint64:
+		return compareUint(v, value)
+
+	case float32:
+		return compareFloat(float64(v), value)
+	case float64:
+		return compareFloat(v, value)
+
+	case complex64:
+		return compareComplex(complex128(v), value)
+	case complex128:
+		return compareComplex(v, value)
+	}
+
+	return 0, false
+}
+
+func compareInt(v int64, value string) (int, bool) {
+	i, err := strconv.ParseInt(value, 0, 64)
+	if err != nil {
+		return compareFloat(float64(v), value)
+	}
+
+	switch {
+	case v < i:
+		return -1, true
+	case v > i:
+		return 1, true
+	}
+	return 0, true
+}
+
+func compareUint(v uint64, value string) (int, bool) {
+	u, err := strconv.ParseUint(value, 0, 64)
+	if err != nil {
+		// a negative value is always smaller
+		if _, err := strconv.ParseInt(value, 0, 64); err == nil {
+			return 1, true
+		}
+		return compareFloat(float64(v), value)
+	}
+
+	switch {
+	case v < u:
+		return -1, true
+	case v > u:
+		return 1, true
+	}
+	return 0, true
+}
+
+func compareFloat(v float64, value string) (int, bool) {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	switch {
+	case v < f:
+		return -1, true
+	case v > f:
+		return 1, true
+	case v == f:
+		return 0, true
+	}
+
+	// NaN on either side isn't comparable
+	return 0, false
+}
+
+func compareComplex(v complex128, value string) (int, bool) {
+	c, err := strconv.ParseComplex(value, 128)
+	if err != nil {
+		return 0, false
+	}
+	if v == c {
+		return 0, true
+	}
+	return 1, true
+}
@@ -0,0 +1,160 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reader
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+
+	"github.com/ScottMansfield/nanolog"
+)
+
+func TestFilter(t *testing.T) {
+	inbuf := &bytes.Buffer{}
+	lw := nanolog.New()
+	lw.SetWriter(inbuf)
+
+	hReq := lw.AddLogger("request %s took %u32 ms")
+	hErr := lw.AddLeveledLogger(nanolog.LevelError, "error %s code %i")
+	hAll := lw.AddLogger("everything %b %s %i %i8 %i16 %i32 %i64 %u %u8 %u16 %u32 %u64 %f32 %f64 %c64 %c128")
+
+	lw.Log(hReq, "/foo", uint32(200))
+	lw.Log(hAll,
+		true, "skip me",
+		int(4), int8(4), int16(4), int32(4), int64(4),
+		uint(4), uint8(4), uint16(4), uint32(4), uint64(4),
+		float32(4), float64(4),
+		complex(float32(4), float32(4)), complex(float64(4), float64(4)),
+	)
+	lw.Log(hErr, "timeout", -1)
+	lw.Log(hReq, "/bar", uint32(900))
+	lw.Flush()
+
+	warn := nanolog.LevelWarn
+
+	pred := func(s string) Predicate {
+		p, err := ParsePredicate(s)
+		if err != nil {
+			t.Fatalf("Got error parsing predicate %q: %v", s, err)
+		}
+		return p
+	}
+
+	tests := map[string]struct {
+		filter   *Filter
+		expected string
+	}{
+		"None": {
+			filter:   nil,
+			expected: "request /foo took 200 ms\neverything true skip me 4 4 4 4 4 4 4 4 4 4 4 4 (4+4i) (4+4i)\nerror timeout code -1\nrequest /bar took 900 ms\n",
+		},
+		"Handle": {
+			filter:   &Filter{Handles: []nanolog.Handle{hErr}},
+			expected: "error timeout code -1\n",
+		},
+		"Format": {
+			filter:   &Filter{Format: regexp.MustCompile(`^request `)},
+			expected: "request /foo took 200 ms\nrequest /bar took 900 ms\n",
+		},
+		"Level": {
+			filter:   &Filter{MinLevel: &warn},
+			expected: "error timeout code -1\n",
+		},
+		"ArgGreater": {
+			filter:   &Filter{Args: []Predicate{pred("arg1 > 500")}},
+			expected: "everything true skip me 4 4 4 4 4 4 4 4 4 4 4 4 (4+4i) (4+4i)\nrequest /bar took 900 ms\n",
+		},
+		"ArgGreaterForHandle": {
+			filter:   &Filter{Handles: []nanolog.Handle{hReq}, Args: []Predicate{pred("arg1 > 500")}},
+			expected: "request /bar took 900 ms\n",
+		},
+		"ArgRegex": {
+			filter:   &Filter{Args: []Predicate{pred("arg0 =~ ^/f")}},
+			expected: "request /foo took 200 ms\n",
+		},
+		"ArgRegexLiteral": {
+			filter:   &Filter{Args: []Predicate{{Arg: 0, Op: "=~", Value: "^/b"}}},
+			expected: "request /bar took 900 ms\n",
+		},
+		"ArgNegative": {
+			filter:   &Filter{Args: []Predicate{pred("arg1<0")}},
+			expected: "error timeout code -1\n",
+		},
+		"ArgOutOfRange": {
+			filter:   &Filter{Args: []Predicate{pred("arg15 == (4+4i)")}},
+			expected: "everything true skip me 4 4 4 4 4 4 4 4 4 4 4 4 (4+4i) (4+4i)\n",
+		},
+		"Combined": {
+			filter:   &Filter{Handles: []nanolog.Handle{hReq, hErr}, Args: []Predicate{pred("arg1 <= 200")}},
+			expected: "request /foo took 200 ms\nerror timeout code -1\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			outbuf := &bytes.Buffer{}
+			r := New(bytes.NewReader(inbuf.Bytes()), outbuf)
+			if err := r.SetFilter(test.filter); err != nil {
+				t.Fatalf("Got error setting the filter: %v", err)
+			}
+
+			if err := r.Inflate(); err != nil {
+				t.Fatalf("Got error during inflate: %v", err)
+			}
+
+			if out := outbuf.String(); out != test.expected {
+				t.Fatalf("Expected:\n%s\nGot:\n%s", test.expected, out)
+			}
+		})
+	}
+}
+
+func TestSetFilterBadRegexp(t *testing.T) {
+	f := &Filter{Args: []Predicate{{Arg: 0, Op: "=~", Value: "("}}}
+	r := New(&bytes.Buffer{}, &bytes.Buffer{})
+
+	if err := r.SetFilter(f); err == nil {
+		t.Fatal("Expected an error for a regular expression that doesn't compile")
+	}
+	if r.filter != nil {
+		t.Fatal("Expected the filter not to be set")
+	}
+
+	// the filter given is left as it is
+	f.Args[0].Value = "^/f"
+	if err := r.SetFilter(f); err != nil {
+		t.Fatalf("Got error setting the filter: %v", err)
+	}
+	if f.Args[0].re != nil || r.filter.Args[0].re == nil {
+		t.Fatal("Expected the regular expression to be compiled into the Reader's copy of the filter")
+	}
+}
+
+func TestParsePredicate(t *testing.T) {
+	for _, s := range []string{"", "foo > 1", "arg > 1", "arg1 ~ 1", "arg1 =~ ("} {
+		if _, err := ParsePredicate(s); err == nil {
+			t.Fatalf("Expected an error parsing %q", s)
+		}
+	}
+
+	p, err := ParsePredicate(" arg12>=-3.5 ")
+	if err != nil {
+		t.Fatalf("Got error parsing predicate: %v", err)
+	}
+	if p.Arg != 12 || p.Op != ">=" || p.Value != "-3.5" {
+		t.Fatalf("Unexpected predicate %+v", p)
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reader
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultPollInterval is how often a Follower checks for new data by default
+const DefaultPollInterval = 250 * time.Millisecond
+
+// Follower reads a log file that is still being written, like tail -f. At the
+// end of the file it waits for more data instead of returning io.EOF, so a
+// partially written record is completed rather than reported as truncated. If
+// the file at the path is replaced, e.g. by log rotation, the Follower finishes
+// the old file and carries on from the start of the new one. If the file is
+// truncated in place it starts again from the beginning.
+//
+// When a Follower is given to New, the Reader's output is flushed whenever the
+// Follower waits for more data.
+type Follower struct {
+	// PollInterval is how long to wait between checks for new data
+	PollInterval time.Duration
+
+	path string
+
+	// guards the files, which are closed by Close
+	mu sync.Mutex
+	f  *os.File
+	// the file that replaced f at the path, once f has been read to the end
+	next *os.File
+
+	// called before waiting for more data
+	wait func()
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// Follow opens the file at path for following
+func Follow(path string) (*Follower, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Follower{
+		PollInterval: DefaultPollInterval,
+		path:         path,
+		f:            f,
+		done:         make(chan struct{}),
+	}, nil
+}
+
+// Read reads from the followed file, blocking until there is data to return or
+// the Follower is closed. After Close, Read returns io.EOF.
+func (f *Follower) Read(p []byte) (int, error) {
+	for {
+		n, again, err := f.read(p)
+		if n > 0 || err != nil {
+			return n, err
+		}
+		if again {
+			continue
+		}
+
+		if f.wait != nil {
+			f.wait()
+		}
+
+		select {
+		case <-f.done:
+			return 0, io.EOF
+		case <-time.After(f.PollInterval):
+		}
+	}
+}
+
+// read does a single read from the current file. If there is no data, again
+// is true when the file has changed and should be read from right away.
+func (f *Follower) read(p []byte) (n int, again bool, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed() {
+		return 0, false, io.EOF
+	}
+
+	n, err = f.f.Read(p)
+	if n > 0 {
+		return n, false, nil
+	}
+	if err != nil && err != io.EOF {
+		return 0, false, err
+	}
+
+	// At the end of the file. Either more will be written to it or it has
+	// been rotated or truncated.
+	again, err = f.reopen()
+	return 0, again, err
+}
+
+// reopen checks if the file at the path is no longer the one being read, or if
+// it has been truncated, and if so starts reading the new contents.
+func (f *Follower) reopen() (bool, error) {
+	if f.next != nil {
+		// the old file has been read to the end since the new one showed up
+		f.f.Close()
+		f.f = f.next
+		f.next = nil
+		return true, nil
+	}
+
+	cur, err := f.f.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	st, err := os.Stat(f.path)
+	if os.IsNotExist(err) {
+		// in the middle of a rotation, the new file isn't there yet
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if !os.SameFile(cur, st) {
+		nf, err := os.Open(f.path)
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		// there may have been a last write to the old file since it was read,
+		// so read it once more before switching
+		f.next = nf
+		return true, nil
+	}
+
+	off, err := f.f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return false, err
+	}
+
+	if st.Size() < off {
+		if _, err := f.f.Seek(0, io.SeekStart); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (f *Follower) closed() bool {
+	select {
+	case <-f.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close stops following. Any pending or later Read returns io.EOF.
+func (f *Follower) Close() error {
+	var err error
+
+	f.closeOnce.Do(func() {
+		close(f.done)
+
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		err = f.f.Close()
+		if f.next != nil {
+			f.next.Close()
+		}
+	})
+
+	return err
+}
@@ -0,0 +1,126 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reader
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ScottMansfield/nanolog"
+)
+
+func TestFollow(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nanolog-follow")
+	if err != nil {
+		t.Fatalf("Got error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "foo.clog")
+
+	// generate the bytes for a log line and three entries
+	buf := &bytes.Buffer{}
+	lw := nanolog.New()
+	lw.SetWriter(buf)
+	h := lw.AddLogger("entry %i")
+	lw.Flush()
+	header := append([]byte(nil), buf.Bytes()...)
+
+	entries := make([][]byte, 3)
+	for i := range entries {
+		buf.Reset()
+		lw.Log(h, i)
+		lw.Flush()
+		entries[i] = append([]byte(nil), buf.Bytes()...)
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Got error creating file: %v", err)
+	}
+
+	// the first entry is only half written to start with
+	out.Write(header)
+	out.Write(entries[0][:3])
+
+	f, err := Follow(path)
+	if err != nil {
+		t.Fatalf("Got error following file: %v", err)
+	}
+	f.PollInterval = time.Millisecond
+
+	recs := make(chan Record)
+	errs := make(chan error, 1)
+	go func() {
+		r := New(f, ioutil.Discard)
+		for {
+			rec, err := r.Next()
+			if err != nil {
+				errs <- err
+				return
+			}
+			recs <- rec
+		}
+	}()
+
+	expect := func(i int) {
+		select {
+		case rec := <-recs:
+			if rec.Args[0] != i {
+				t.Fatalf("Expected entry %d but got %v", i, rec.Args[0])
+			}
+		case err := <-errs:
+			t.Fatalf("Got error while following: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for entry %d", i)
+		}
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	out.Write(entries[0][3:])
+	expect(0)
+
+	// rotate: the last write to the old file comes after the new file exists
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatalf("Got error renaming file: %v", err)
+	}
+	newOut, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Got error creating file: %v", err)
+	}
+	out.Write(entries[1])
+	out.Close()
+	expect(1)
+
+	newOut.Write(entries[2])
+	newOut.Close()
+	expect(2)
+
+	f.Close()
+
+	select {
+	case err := <-errs:
+		if err != io.EOF {
+			t.Fatalf("Expected io.EOF after Close but got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for io.EOF after Close")
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reader
+
+import (
+	"io"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/ScottMansfield/nanolog"
+)
+
+// IndexPoint is a checkpoint in a log, as recorded in an index file
+type IndexPoint struct {
+	// Offset is the position of a log entry record in the log
+	Offset int64
+	// Entry is the number of entries before that record
+	Entry uint64
+	// Time is when the checkpoint was written, which is the time of the entry
+	// if the log has timestamps
+	Time time.Time
+}
+
+// Index is the contents of an index file written with nanolog.WithIndex. It is
+// used to start reading a log from the middle instead of from the start.
+type Index struct {
+	// Points are the checkpoints, in the order they were written
+	Points []IndexPoint
+	// Dictionary is set on the Readers returned by SeekTime and SeekEntry, for
+	// logs written with a dictionary
+	Dictionary *nanolog.Dictionary
+
+	loggers map[uint32]nanolog.Logger
+}
+
+// ReadIndex reads a whole index file
+func ReadIndex(in io.Reader) (*Index, error) {
+	r := New(in, ioutil.Discard)
+	idx := &Index{}
+
+	for {
+		rawType, err := r.readByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if nanolog.EntryType(rawType) != nanolog.ETIndex {
+			if err := r.skipRecord(rawType); err != nil {
+				return nil, noEOF(err)
+			}
+			continue
+		}
+
+		p, err := r.readIndexPoint()
+		if err != nil {
+			return nil, noEOF(err)
+		}
+
+		idx.Points = append(idx.Points, p)
+	}
+
+	idx.loggers = r.loggers
+
+	return idx, nil
+}
+
+// SeekTime returns a Reader for the log that starts at the first entry logged
+// at or after t. It jumps to the last checkpoint before t and decodes forward
+// from there. If the log has no timestamps the Reader starts at that
+// checkpoint instead.
+func (idx *Index) SeekTime(log io.ReadSeeker, w io.Writer, t time.Time) (*Reader, error) {
+	i := sort.Search(len(idx.Points), func(i int) bool {
+		return idx.Points[i].Time.After(t)
+	})
+
+	return idx.seek(log, w, i-1, func(rec Record) bool {
+		return !rec.Time.IsZero() && rec.Time.Before(t)
+	})
+}
+
+// SeekEntry returns a Reader for the log that starts at the entry with the
+// given number, counting from 0. It jumps to the last checkpoint before it and
+// decodes forward from there.
+func (idx *Index) SeekEntry(log io.ReadSeeker, w io.Writer, n uint64) (*Reader, error) {
+	i := sort.Search(len(idx.Points), func(i int) bool {
+		return idx.Points[i].Entry > n
+	})
+
+	return idx.seek(log, w, i-1, func(rec Record) bool {
+		return rec.Entry < n
+	})
+}
+
+// seek starts a Reader at the i'th point, or the start of the log if i is -1,
+// and reads past the entries for which skip returns true.
+func (idx *Index) seek(log io.ReadSeeker, w io.Writer, i int, skip func(Record) bool) (*Reader, error) {
+	var p IndexPoint
+	if i >= 0 {
+		p = idx.Points[i]
+	}
+
+	if _, err := log.Seek(p.Offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	r := New(log, w)
+	r.off = p.Offset
+	r.entry = p.Entry
+
+	if idx.Dictionary != nil {
+		if err := r.SetDictionary(idx.Dictionary); err != nil {
+			return nil, err
+		}
+	}
+
+	for id, l := range idx.loggers {
+		r.loggers[id] = l
+	}
+
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			return r, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if !skip(rec) {
+			r.peeked = &rec
+			return r, nil
+		}
+	}
+}
+
+func (r *Reader) readIndexPoint() (IndexPoint, error) {
+	off, err := r.readUint64()
+	if err != nil {
+		return IndexPoint{}, err
+	}
+
+	entry, err := r.readUint64()
+	if err != nil {
+		return IndexPoint{}, err
+	}
+
+	nanos, err := r.readUint64()
+	if err != nil {
+		return IndexPoint{}, err
+	}
+
+	return IndexPoint{
+		Offset: int64(off),
+		Entry:  entry,
+		Time:   time.Unix(0, int64(nanos)),
+	}, nil
+}
@@ -0,0 +1,118 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reader
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ScottMansfield/nanolog"
+)
+
+func TestIndexSeek(t *testing.T) {
+	logbuf, idxbuf := &bytes.Buffer{}, &bytes.Buffer{}
+
+	lw := nanolog.New(nanolog.WithTimestamps(true), nanolog.WithIndex(idxbuf, 10))
+	lw.SetWriter(logbuf)
+
+	hEven := lw.AddLogger("even %i")
+	var hOdd nanolog.Handle
+
+	times := make([]time.Time, 100)
+	for i := range times {
+		// a log line added partway through must still be found through the index
+		if i == 45 {
+			hOdd = lw.AddLogger("odd %i")
+		}
+
+		times[i] = time.Now()
+		if i%2 == 1 && i > 45 {
+			lw.Log(hOdd, i)
+		} else {
+			lw.Log(hEven, i)
+		}
+		time.Sleep(10 * time.Microsecond)
+	}
+	lw.Flush()
+
+	idx, err := ReadIndex(idxbuf)
+	if err != nil {
+		t.Fatalf("Got error reading index: %v", err)
+	}
+	if len(idx.Points) != 10 {
+		t.Fatalf("Expected 10 index points but got %d", len(idx.Points))
+	}
+
+	check := func(name string, r *Reader, err error, first int) {
+		if err != nil {
+			t.Fatalf("%s: Got error seeking: %v", name, err)
+		}
+
+		for i := first; i < len(times); i++ {
+			rec, err := r.Next()
+			if err != nil {
+				t.Fatalf("%s: Got error reading entry %d: %v", name, i, err)
+			}
+			if rec.Entry != uint64(i) || rec.Args[0] != i {
+				t.Fatalf("%s: Expected entry %d but got entry %d with %v", name, i, rec.Entry, rec.Args)
+			}
+		}
+
+		if _, err := r.Next(); err != io.EOF {
+			t.Fatalf("%s: Expected io.EOF at the end but got %v", name, err)
+		}
+	}
+
+	for _, n := range []int{0, 9, 10, 37, 55, 99} {
+		r, err := idx.SeekEntry(bytes.NewReader(logbuf.Bytes()), &bytes.Buffer{}, uint64(n))
+		check("SeekEntry", r, err, n)
+
+		r, err = idx.SeekTime(bytes.NewReader(logbuf.Bytes()), &bytes.Buffer{}, times[n])
+		check("SeekTime", r, err, n)
+	}
+
+	// before the start and after the end
+	r, err := idx.SeekTime(bytes.NewReader(logbuf.Bytes()), &bytes.Buffer{}, times[0].Add(-time.Hour))
+	check("SeekTimeBefore", r, err, 0)
+
+	r, err = idx.SeekTime(bytes.NewReader(logbuf.Bytes()), &bytes.Buffer{}, time.Now().Add(time.Hour))
+	check("SeekTimeAfter", r, err, len(times))
+}
+
+func TestSetUntil(t *testing.T) {
+	buf := &bytes.Buffer{}
+	lw := nanolog.New(nanolog.WithTimestamps(true))
+	lw.SetWriter(buf)
+	h := lw.AddLogger("%i")
+
+	lw.Log(h, 0)
+	time.Sleep(time.Millisecond)
+	until := time.Now()
+	time.Sleep(time.Millisecond)
+	lw.Log(h, 1)
+	lw.Flush()
+
+	r := New(buf, &bytes.Buffer{})
+	r.SetUntil(until)
+
+	if rec, err := r.Next(); err != nil || rec.Args[0] != 0 {
+		t.Fatalf("Expected the first entry but got %v, %v", rec.Args, err)
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Fatalf("Expected io.EOF after the until time but got %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reader
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+
+	"github.com/ScottMansfield/nanolog"
+)
+
+type jsonRecord struct {
+	Message string    `json:"message"`
+	Handle  uint32    `json:"handle"`
+	Name    string    `json:"name,omitempty"`
+	Level   string    `json:"level,omitempty"`
+	Format  string    `json:"format"`
+	Args    []jsonArg `json:"args"`
+	Source  string    `json:"source,omitempty"`
+	File    string    `json:"file,omitempty"`
+	Line    int       `json:"line,omitempty"`
+	Func    string    `json:"func,omitempty"`
+}
+
+type jsonArg struct {
+	Kind  string      `json:"kind"`
+	Value interface{} `json:"value"`
+}
+
+// MarshalJSON encodes the record as a JSON object containing the rendered
+// message, the handle, the format string, a typed array of the arguments and,
+// when they are known, the name and level of the log line, the source of merged
+// records and the source location of the log line. The level is left out for
+// LevelInfo, which is what log lines without one are at. Numbers are written
+// with their exact value. Floats that JSON can't represent (NaN and the
+// infinities) are written as strings and complex numbers are written as a two
+// element [real, imaginary] array.
+func (rec Record) MarshalJSON() ([]byte, error) {
+	jr := jsonRecord{
+		Message: rec.Message(),
+		Handle:  uint32(rec.Handle),
+		Name:    rec.Name,
+		Level:   jsonLevel(rec.Level),
+		Format:  rec.Format(),
+		Args:    make([]jsonArg, len(rec.Args)),
+		Source:  rec.Source,
+		File:    rec.File,
+		Line:    rec.Line,
+		Func:    rec.Func,
+	}
+
+	for i, arg := range rec.Args {
+		jr.Args[i] = jsonArg{
+			Kind:  rec.Kinds[i].String(),
+			Value: jsonValue(arg),
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(jr); err != nil {
+		return nil, err
+	}
+
+	// Encode always adds a newline after the value
+	return bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}), nil
+}
+
+func jsonLevel(l nanolog.Level) string {
+	if l == nanolog.LevelInfo {
+		return ""
+	}
+	return l.String()
+}
+
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case float32:
+		return jsonFloat(float64(v), v)
+	case float64:
+		return jsonFloat(v, v)
+	case complex64:
+		return []interface{}{jsonFloat(float64(real(v)), real(v)), jsonFloat(float64(imag(v)), imag(v))}
+	case complex128:
+		return []interface{}{jsonFloat(real(v), real(v)), jsonFloat(imag(v), imag(v))}
+	}
+
+	return v
+}
+
+// jsonFloat returns orig unless f can't be represented as a JSON number.
+// orig is kept as is so float32 values are encoded with 32 bit precision.
+func jsonFloat(f float64, orig interface{}) interface{} {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+
+	return orig
+}
+
+// InflateJSON will read from the supplied reader and write each log entry to
+// the supplied writer as a JSON object on its own line (JSON Lines)
+func (r *Reader) InflateJSON() error {
+	return inflate(r.Next, r.w, jsonWriter(r.w))
+}
+
+func jsonWriter(w io.Writer) func(Record) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+
+	return func(rec Record) error {
+		return enc.Encode(rec)
+	}
+}
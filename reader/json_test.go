@@ -0,0 +1,55 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reader
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/ScottMansfield/nanolog"
+)
+
+func TestInflateJSON(t *testing.T) {
+	inbuf := &bytes.Buffer{}
+	lw := nanolog.New()
+	lw.SetWriter(inbuf)
+
+	h := lw.AddLogger("id %u64 ratio %f64 name %s %c64 100%%")
+	lw.Log(h, uint64(math.MaxUint64), 0.1, "<foo>", complex(float32(1), float32(2)))
+	lw.Log(h, uint64(0), math.Inf(-1), "", complex(float32(math.NaN()), float32(0)))
+	lw.Flush()
+
+	outbuf := &bytes.Buffer{}
+	if err := New(inbuf, outbuf).InflateJSON(); err != nil {
+		t.Fatalf("Got error during inflate: %v", err)
+	}
+
+	expected := `{"message":"id 18446744073709551615 ratio 0.1 name <foo> (1+2i) 100%","handle":0,` +
+		`"format":"id %u64 ratio %f64 name %s %c64 100%%","args":[{"kind":"uint64","value":18446744073709551615},` +
+		`{"kind":"float64","value":0.1},{"kind":"string","value":"<foo>"},{"kind":"complex64","value":[1,2]}]}` + "\n" +
+		`{"message":"id 0 ratio -Inf name  (NaN+0i) 100%","handle":0,` +
+		`"format":"id %u64 ratio %f64 name %s %c64 100%%","args":[{"kind":"uint64","value":0},` +
+		`{"kind":"float64","value":"-Inf"},{"kind":"string","value":""},{"kind":"complex64","value":["NaN",0]}]}` + "\n"
+
+	if out := outbuf.String(); out != expected {
+		t.Fatalf("Unexpected output.\nExpected:\n%s\nGot:\n%s", expected, out)
+	}
+
+	if strings.Count(outbuf.String(), "\n") != 2 {
+		t.Fatalf("Expected one line per entry")
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reader
+
+import (
+	"bufio"
+	"container/heap"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+	"text/template"
+
+	"github.com/ScottMansfield/nanolog"
+)
+
+// how many decoded records each input can get ahead of the merged output
+const mergeBuffer = 256
+
+// Source is a named input to a Merger
+type Source struct {
+	// Name identifies the input in the merged records, e.g. the file name
+	Name string
+	R    io.Reader
+	// Dictionary is the dictionary the input was written with, if any
+	Dictionary *nanolog.Dictionary
+}
+
+// Merger interleaves the entries of several inputs into a single stream ordered
+// by time. Each input is decoded concurrently by its own Reader, so every input
+// keeps its own handle table. The inputs need to be written with timestamps
+// turned on; entries without a timestamp sort before all others. Entries with
+// the same time are returned in the order of the inputs.
+type Merger struct {
+	w       *bufio.Writer
+	sources []Source
+
+	start sync.Once
+	done  chan struct{}
+	wg    sync.WaitGroup
+	heads mergeHeap
+	// inputs that haven't had their next record added to heads yet
+	pending []*mergeInput
+}
+
+type mergeInput struct {
+	idx  int
+	name string
+	recs chan mergeResult
+}
+
+type mergeResult struct {
+	rec Record
+	err error
+}
+
+// NewMerger creates a Merger for the sources that writes its output to w
+func NewMerger(w io.Writer, sources ...Source) *Merger {
+	return &Merger{
+		w:       bufio.NewWriter(w),
+		sources: sources,
+		done:    make(chan struct{}),
+	}
+}
+
+func (m *Merger) run() {
+	for i, src := range m.sources {
+		in := &mergeInput{
+			idx:  i,
+			name: src.Name,
+			recs: make(chan mergeResult, mergeBuffer),
+		}
+		m.pending = append(m.pending, in)
+
+		m.wg.Add(1)
+		go m.decode(src, in)
+	}
+}
+
+// decode reads every record from the source and hands them to the merge
+func (m *Merger) decode(src Source, in *mergeInput) {
+	defer m.wg.Done()
+	defer close(in.recs)
+
+	r := New(src.R, ioutil.Discard)
+
+	if src.Dictionary != nil {
+		if err := r.SetDictionary(src.Dictionary); err != nil {
+			select {
+			case in.recs <- mergeResult{err: err}:
+			case <-m.done:
+			}
+			return
+		}
+	}
+
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			return
+		}
+		if err == nil {
+			rec.Source = src.Name
+		}
+
+		select {
+		case in.recs <- mergeResult{rec: rec, err: err}:
+		case <-m.done:
+			return
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Next returns the earliest entry not yet returned across all of the inputs.
+// It returns io.EOF once every input has been read to the end. An error from
+// any input stops the merge and is returned with the name of the input.
+func (m *Merger) Next() (Record, error) {
+	m.start.Do(m.run)
+
+	// wait for the next record of every input that doesn't have one queued
+	for _, in := range m.pending {
+		res, ok := <-in.recs
+		if !ok {
+			continue
+		}
+
+		if res.err != nil {
+			m.Close()
+			return Record{}, fmt.Errorf("%s: %v", in.name, res.err)
+		}
+
+		heap.Push(&m.heads, mergeHead{rec: res.rec, in: in})
+	}
+	m.pending = m.pending[:0]
+
+	if m.heads.Len() == 0 {
+		return Record{}, io.EOF
+	}
+
+	head := heap.Pop(&m.heads).(mergeHead)
+	m.pending = append(m.pending, head.in)
+
+	return head.rec, nil
+}
+
+// Close stops decoding the inputs. It does not close them.
+func (m *Merger) Close() {
+	m.start.Do(func() {})
+
+	select {
+	case <-m.done:
+	default:
+		close(m.done)
+	}
+
+	m.wg.Wait()
+}
+
+// Inflate writes the merged entries as text to the writer. Each line is
+// prefixed with the name of the source it came from.
+func (m *Merger) Inflate() error {
+	defer m.Close()
+	return inflate(m.Next, m.w, textWriter(m.w))
+}
+
+// InflateJSON writes the merged entries as JSON Lines to the writer
+func (m *Merger) InflateJSON() error {
+	defer m.Close()
+	return inflate(m.Next, m.w, jsonWriter(m.w))
+}
+
+// InflateTemplate writes the merged entries to the writer using the template
+func (m *Merger) InflateTemplate(tmpl *template.Template) error {
+	defer m.Close()
+	return inflate(m.Next, m.w, templateWriter(m.w, tmpl))
+}
+
+type mergeHead struct {
+	rec Record
+	in  *mergeInput
+}
+
+// mergeHeap orders the next record of each input by time, then input order
+type mergeHeap []mergeHead
+
+func (h mergeHeap) Len() int { return len(h) }
+
+func (h mergeHeap) Less(i, j int) bool {
+	if !h[i].rec.Time.Equal(h[j].rec.Time) {
+		return h[i].rec.Time.Before(h[j].rec.Time)
+	}
+	return h[i].in.idx < h[j].in.idx
+}
+
+func (h mergeHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *mergeHeap) Push(x interface{}) { *h = append(*h, x.(mergeHead)) }
+
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,79 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reader
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ScottMansfield/nanolog"
+)
+
+func TestMerge(t *testing.T) {
+	bufA, bufB := &bytes.Buffer{}, &bytes.Buffer{}
+
+	lwA := nanolog.New(nanolog.WithTimestamps(true))
+	lwA.SetWriter(bufA)
+	lwB := nanolog.New(nanolog.WithTimestamps(true))
+	lwB.SetWriter(bufB)
+
+	// the same handle numbers mean different things in each file
+	hA1 := lwA.AddLogger("a one %i")
+	hA2 := lwA.AddLogger("a two %s")
+	hB1 := lwB.AddLogger("b one %u8")
+
+	log := func(lw nanolog.LogWriter, h nanolog.Handle, arg interface{}) {
+		lw.Log(h, arg)
+		time.Sleep(time.Millisecond)
+	}
+
+	log(lwB, hB1, uint8(1))
+	log(lwA, hA1, 2)
+	log(lwA, hA2, "three")
+	log(lwB, hB1, uint8(4))
+	log(lwA, hA1, 5)
+
+	lwA.Flush()
+	lwB.Flush()
+
+	outbuf := &bytes.Buffer{}
+	m := NewMerger(outbuf, Source{Name: "a", R: bufA}, Source{Name: "b", R: bufB})
+	if err := m.Inflate(); err != nil {
+		t.Fatalf("Got error during merge: %v", err)
+	}
+
+	expected := "b: b one 1\na: a one 2\na: a two three\nb: b one 4\na: a one 5\n"
+	if out := outbuf.String(); out != expected {
+		t.Fatalf("Expected:\n%s\nGot:\n%s", expected, out)
+	}
+}
+
+func TestMergeError(t *testing.T) {
+	good := &bytes.Buffer{}
+	lw := nanolog.New(nanolog.WithTimestamps(true))
+	lw.SetWriter(good)
+	lw.Log(lw.AddLogger("fine"))
+	lw.Flush()
+
+	bad := bytes.NewReader([]byte{byte(nanolog.ETInvalid)})
+
+	m := NewMerger(&bytes.Buffer{}, Source{Name: "good", R: good}, Source{Name: "bad", R: bad})
+	err := m.Inflate()
+	if err == nil || !strings.HasPrefix(err.Error(), "bad: ") {
+		t.Fatalf("Expected an error from the bad input but got %v", err)
+	}
+}
@@ -19,265 +19,708 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"math"
 	"reflect"
+	"strings"
+	"time"
 
 	"github.com/ScottMansfield/nanolog"
 )
 
+// ErrBadFormat is returned when the input contains a record type that is not understood
+var ErrBadFormat = errors.New("Bad file format")
+
 // Reader enables reading of the compressed file format
 type Reader struct {
 	r *bufio.Reader
 	w *bufio.Writer
+
+	loggers map[uint32]nanolog.Logger
+
+	filter *Filter
+	// whether each log line passes the handle and format parts of the filter
+	lineOK map[uint32]bool
+
+	// entry read by Seek that has yet to be returned
+	peeked *Record
+	// Next stops at the first entry after this time, if it is set
+	until   time.Time
+	stopped bool
+
+	// log lines shared ahead of time, loaded when the log refers to them
+	dict        *nanolog.Dictionary
+	dictLoggers map[uint32]nanolog.Logger
+	// hash of the dictionary the log refers to if none was set
+	missingDict uint64
+
+	// offset of the next unread byte in the input
+	off int64
+	// if set, every byte read is added to it, see Verify
+	sum hash.Hash32
+	// number of the next entry in the input
+	entry uint64
+	buf   [8]byte
+}
+
+// Record is a single decoded log entry. The embedded Logger is the log line
+// the entry was logged against, so Segs and Kinds describe the format and Args
+// holds one decoded value per kind. Name is only set for log lines added with
+// AddNamedLogger, and File, Line and Func are only set if the writer recorded
+// source locations.
+type Record struct {
+	nanolog.Logger
+
+	// Handle is the id of the log line, as written by AddLogger
+	Handle nanolog.Handle
+	// Offset is the position of the start of the record in the input
+	Offset int64
+	// Entry is the number of entries before this one in the input
+	Entry uint64
+	// Time is when the entry was logged. It is the zero time if the writer
+	// was not recording timestamps.
+	Time time.Time
+	// Source is the name of the input the record was read from when merging
+	// several inputs, and empty otherwise
+	Source string
+	// Args are the decoded values, each with the Go type matching its kind
+	Args []interface{}
 }
 
 // New creates a new Reader with the given reader and writer
 func New(r io.Reader, w io.Writer) *Reader {
-	return &Reader{
-		r: bufio.NewReader(r),
-		w: bufio.NewWriter(w),
+	ret := &Reader{
+		r:       bufio.NewReader(r),
+		w:       bufio.NewWriter(w),
+		loggers: make(map[uint32]nanolog.Logger),
+	}
+
+	// show what has been inflated so far while waiting for more
+	if f, ok := r.(*Follower); ok {
+		f.wait = func() { ret.w.Flush() }
+	}
+
+	return ret
+}
+
+// Logger returns the log line definition for the given handle, if one has
+// been read so far
+func (r *Reader) Logger(h nanolog.Handle) (nanolog.Logger, bool) {
+	l, ok := r.loggers[uint32(h)]
+	return l, ok
+}
+
+// Next reads up to and including the next log entry and returns it decoded.
+// Log line records are consumed along the way. If a filter is set, entries that
+// don't match it are skipped. At the end of the input Next returns io.EOF; if
+// the input ends in the middle of a record the error is io.ErrUnexpectedEOF.
+func (r *Reader) Next() (Record, error) {
+	if r.stopped {
+		return Record{}, io.EOF
+	}
+
+	var rec Record
+
+	if r.peeked != nil {
+		rec = *r.peeked
+		r.peeked = nil
+
+		// the filter may have been set since the entry was read
+		if r.filter != nil && !(r.lineOK[uint32(rec.Handle)] && r.filter.matchArgs(rec)) {
+			return r.Next()
+		}
+	} else {
+		var err error
+		if rec, err = r.next(); err != nil {
+			return Record{}, err
+		}
+	}
+
+	if !r.until.IsZero() && rec.Time.After(r.until) {
+		r.stopped = true
+		return Record{}, io.EOF
+	}
+
+	return rec, nil
+}
+
+// SetUntil makes Next return io.EOF once it reaches an entry logged after t.
+// This relies on the entries being in time order, which they are when written
+// by a single LogWriter.
+func (r *Reader) SetUntil(t time.Time) {
+	r.until = t
+}
+
+// next reads the next entry that matches the filter
+func (r *Reader) next() (Record, error) {
+	for {
+		off := r.off
+
+		rawType, err := r.readByte()
+		if err != nil {
+			return Record{}, err
+		}
+
+		switch nanolog.EntryType(rawType) {
+		case nanolog.ETLogEntry, nanolog.ETTimedLogEntry:
+			entry := r.entry
+			r.entry++
+
+			rec, ok, err := r.readLogEntry(nanolog.EntryType(rawType) == nanolog.ETTimedLogEntry)
+			if err != nil {
+				return Record{}, noEOF(err)
+			}
+			if !ok {
+				continue
+			}
+
+			rec.Offset = off
+			rec.Entry = entry
+			return rec, nil
+
+		default:
+			if err := r.skipRecord(rawType); err != nil {
+				return Record{}, noEOF(err)
+			}
+		}
+	}
+}
+
+// skipRecord reads past a record of the given type, whose type byte has been
+// read. The log lines and dictionaries it refers to are kept so that the
+// entries after it can be decoded, but entries are skipped without being
+// decoded. Unknown types are ErrBadFormat.
+func (r *Reader) skipRecord(rawType byte) error {
+	var err error
+
+	switch nanolog.EntryType(rawType) {
+	case nanolog.ETLogLine:
+		_, err = r.readLogLine()
+
+	case nanolog.ETLogEntry, nanolog.ETTimedLogEntry:
+		_, _, err = r.skipLogEntry(nanolog.EntryType(rawType) == nanolog.ETTimedLogEntry)
+
+	case nanolog.ETIndex:
+		// only expected in index files, but harmless anywhere
+		_, err = r.readIndexPoint()
+
+	case nanolog.ETDictionary:
+		err = r.readDictionaryRef()
+
+	case nanolog.ETTrailer:
+		// more may follow if another log was appended after it
+		_, err = r.readTrailer()
+
+	default:
+		err = ErrBadFormat
 	}
+
+	return err
 }
 
 // Inflate will read from the supplied reader and inflate the contents into the
 // supplied writer
 func (r *Reader) Inflate() error {
-	loggers := make(map[uint32]nanolog.Logger)
+	return inflate(r.Next, r.w, textWriter(r.w))
+}
 
+// inflate passes each record from next to write until next returns io.EOF,
+// flushing w at the end or on error
+func inflate(next func() (Record, error), w *bufio.Writer, write func(Record) error) error {
 	for {
-		rawType, err := r.r.ReadByte()
+		rec, err := next()
 		if err == io.EOF {
 			break
 		}
+		if err == nil {
+			err = write(rec)
+		}
 		if err != nil {
+			w.Flush()
 			return err
 		}
+	}
+
+	return w.Flush()
+}
+
+// textWriter writes records as their message followed by a newline. Records
+// from a merge are prefixed with their source.
+func textWriter(w *bufio.Writer) func(Record) error {
+	return func(rec Record) error {
+		if rec.Source != "" {
+			w.WriteString(rec.Source)
+			w.WriteString(": ")
+		}
+
+		w.WriteString(rec.Message())
+		return w.WriteByte('\n')
+	}
+}
+
+// Message renders the record the same way Inflate does, minus the newline
+func (rec Record) Message() string {
+	return rec.Render(nil)
+}
+
+// Render renders the record like Message, except that arguments whose kind is
+// in verbs are formatted with that fmt verb, e.g. "%.3f" for reflect.Float64.
+// The verbs take precedence over the modifiers in the format string.
+func (rec Record) Render(verbs map[reflect.Kind]string) string {
+	sb := &strings.Builder{}
+
+	sb.WriteString(rec.Segs[0])
+
+	for i, arg := range rec.Args {
+		if verb, ok := verbs[rec.Kinds[i]]; ok {
+			fmt.Fprintf(sb, verb, arg)
+		} else if verb := rec.Verb(i); verb != "" {
+			fmt.Fprintf(sb, verb, arg)
+		} else {
+			fmt.Fprint(sb, arg)
+		}
+
+		sb.WriteString(rec.Segs[i+1])
+	}
 
-		recordType := nanolog.EntryType(rawType)
+	return sb.String()
+}
+
+func (r *Reader) readLogLine() (uint32, error) {
+	logger := nanolog.Logger{}
+
+	// First comes the line ID
+	id, err := r.readUint32()
+	if err != nil {
+		return 0, err
+	}
 
-		switch recordType {
-		case nanolog.ETLogLine:
-			logger := nanolog.Logger{}
+	// Then the number of string segments, which also flags the attributes
+	numsegs, err := r.readUint32()
+	if err != nil {
+		return 0, err
+	}
 
-			buf := make([]byte, 4)
+	hasAttrs := numsegs&nanolog.SegsHasAttrs != 0
+	numsegs &^= nanolog.SegsHasAttrs
 
-			// First comes the line ID
-			if _, err := io.ReadAtLeast(r.r, buf, len(buf)); err != nil {
-				return err
+	if numsegs == 0 {
+		return 0, ErrBadFormat
+	}
+
+	// read in the kinds, numsegs - 1 of them
+	for i := uint32(0); i < numsegs-1; i++ {
+		b, err := r.readByte()
+		if err != nil {
+			return 0, err
+		}
+
+		logger.Kinds = append(logger.Kinds, reflect.Kind(b))
+	}
+
+	// read in the string segments that surround the interpolations
+	for i := uint32(0); i < numsegs; i++ {
+		s, err := r.readString()
+		if err != nil {
+			return 0, err
+		}
+
+		logger.Segs = append(logger.Segs, s)
+	}
+
+	if hasAttrs {
+		if err := r.readLineAttrs(&logger); err != nil {
+			return 0, err
+		}
+	}
+
+	r.loggers[id] = logger
+
+	if r.filter != nil {
+		r.lineOK[id] = r.filter.matchLine(nanolog.Handle(id), logger)
+	}
+
+	return id, nil
+}
+
+// unknownHandle is the error for an entry logged against a log line that
+// hasn't been defined
+func (r *Reader) unknownHandle(id uint32) error {
+	if r.missingDict != 0 {
+		return fmt.Errorf("Log entry for unknown handle %d, the log needs dictionary %016x", id, r.missingDict)
+	}
+	return fmt.Errorf("Log entry for unknown handle %d", id)
+}
+
+// readLineAttrs reads the attributes at the end of a log line record into the
+// logger. Attributes with unknown keys are skipped.
+func (r *Reader) readLineAttrs(logger *nanolog.Logger) error {
+	numattrs, err := r.readUint32()
+	if err != nil {
+		return err
+	}
+
+	for i := uint32(0); i < numattrs; i++ {
+		key, err := r.readByte()
+		if err != nil {
+			return err
+		}
+
+		data, err := r.readString()
+		if err != nil {
+			return err
+		}
+
+		switch nanolog.LineAttr(key) {
+		case nanolog.LAModifier:
+			if len(data) < 4 {
+				return ErrBadFormat
 			}
-			id := binary.LittleEndian.Uint32(buf)
 
-			// Then the number of string segments
-			if _, err := io.ReadAtLeast(r.r, buf, len(buf)); err != nil {
-				return err
+			idx := binary.LittleEndian.Uint32([]byte(data[:4]))
+			if idx >= uint32(len(logger.Kinds)) {
+				return ErrBadFormat
 			}
-			numsegs := binary.LittleEndian.Uint32(buf)
 
-			// read in the kinds, numsegs - 1 of them
-			for i := uint32(0); i < numsegs-1; i++ {
-				b, err := r.r.ReadByte()
-				if err != nil {
-					return err
-				}
+			if logger.Mods == nil {
+				logger.Mods = make([]string, len(logger.Kinds))
+			}
+			logger.Mods[idx] = data[4:]
 
-				k := reflect.Kind(b)
-				logger.Kinds = append(logger.Kinds, k)
+		case nanolog.LASource:
+			if len(data) < 8 {
+				return ErrBadFormat
 			}
 
-			// read in the string segments that surround the interpolations
-			for i := uint32(0); i < numsegs; i++ {
-				if _, err := io.ReadAtLeast(r.r, buf, len(buf)); err != nil {
-					return err
-				}
+			filelen := binary.LittleEndian.Uint32([]byte(data[4:8]))
+			if filelen > uint32(len(data)-8) {
+				return ErrBadFormat
+			}
 
-				strlen := binary.LittleEndian.Uint32(buf)
-				strbuf := make([]byte, strlen)
+			logger.Line = int(binary.LittleEndian.Uint32([]byte(data[:4])))
+			logger.File = data[8 : 8+filelen]
+			logger.Func = data[8+filelen:]
 
-				if _, err := io.ReadAtLeast(r.r, strbuf, len(strbuf)); err != nil {
-					return err
-				}
+		case nanolog.LAName:
+			logger.Name = data
 
-				logger.Segs = append(logger.Segs, string(strbuf))
+		case nanolog.LALevel:
+			if len(data) != 1 {
+				return ErrBadFormat
 			}
 
-			loggers[id] = logger
+			logger.Level = nanolog.Level(data[0])
+		}
+	}
 
-		case nanolog.ETLogEntry:
-			smallbuf := make([]byte, 2)
-			buf := make([]byte, 4)
-			longbuf := make([]byte, 8)
-			var toWrite interface{}
+	return nil
+}
 
-			// First comes the line ID
-			if _, err := io.ReadAtLeast(r.r, buf, len(buf)); err != nil {
-				return err
-			}
-			id := binary.LittleEndian.Uint32(buf)
-
-			logger := loggers[id]
-
-			r.w.WriteString(logger.Segs[0])
+// readLogEntry reads the rest of a log entry record. If the entry doesn't match
+// the filter ok is false and the returned Record is empty.
+func (r *Reader) readLogEntry(timed bool) (rec Record, ok bool, err error) {
+	// First comes the line ID
+	id, err := r.readUint32()
+	if err != nil {
+		return Record{}, false, err
+	}
 
-			for i := 1; i < len(logger.Segs); i++ {
-				// first read whatever kind data is needed
-				switch logger.Kinds[i-1] {
-				case reflect.Bool:
-					v, err := r.r.ReadByte()
-					if err != nil {
-						return err
-					}
+	// Then the timestamp, if there is one
+	var ts time.Time
+	if timed {
+		nanos, err := r.readUint64()
+		if err != nil {
+			return Record{}, false, err
+		}
 
-					if v == 0 {
-						toWrite = "false"
-					} else if v == 1 {
-						toWrite = "true"
-					}
+		ts = time.Unix(0, int64(nanos))
+	}
 
-				case reflect.String:
-					// First comes the string length
-					if _, err := io.ReadAtLeast(r.r, buf, len(buf)); err != nil {
-						return err
-					}
-					strlen := binary.LittleEndian.Uint32(buf)
+	logger, ok := r.loggers[id]
+	if !ok {
+		return Record{}, false, r.unknownHandle(id)
+	}
 
-					// copy the string from input to output
-					io.Copy(r.w, io.LimitReader(r.r, int64(strlen)))
+	// skip over the data of log lines the filter rules out without decoding it
+	if r.filter != nil && !r.lineOK[id] {
+		for _, k := range logger.Kinds {
+			if err := r.skipValue(k); err != nil {
+				return Record{}, false, err
+			}
+		}
 
-					toWrite = nil
+		return Record{}, false, nil
+	}
 
-				// ints
-				case reflect.Int:
-					fallthrough
-				case reflect.Int64:
-					if _, err := io.ReadAtLeast(r.r, longbuf, len(longbuf)); err != nil {
-						return err
-					}
+	rec = Record{
+		Logger: logger,
+		Handle: nanolog.Handle(id),
+		Time:   ts,
+		Args:   make([]interface{}, len(logger.Kinds)),
+	}
 
-					toWrite = int64(binary.LittleEndian.Uint64(longbuf))
+	for i, k := range logger.Kinds {
+		v, err := r.readValue(k)
+		if err != nil {
+			return Record{}, false, err
+		}
 
-				case reflect.Int8:
-					b, err := r.r.ReadByte()
-					if err != nil {
-						return err
-					}
+		rec.Args[i] = v
+	}
 
-					toWrite = int8(b)
+	if r.filter != nil && !r.filter.matchArgs(rec) {
+		return Record{}, false, nil
+	}
 
-				case reflect.Int16:
-					if _, err := io.ReadAtLeast(r.r, smallbuf, len(smallbuf)); err != nil {
-						return err
-					}
+	return rec, true, nil
+}
 
-					toWrite = int16(binary.LittleEndian.Uint16(smallbuf))
+// skipLogEntry reads the rest of a log entry record without decoding the data
+func (r *Reader) skipLogEntry(timed bool) (id uint32, ts time.Time, err error) {
+	if id, err = r.readUint32(); err != nil {
+		return 0, time.Time{}, err
+	}
 
-				case reflect.Int32:
-					if _, err := io.ReadAtLeast(r.r, buf, len(buf)); err != nil {
-						return err
-					}
+	if timed {
+		nanos, err := r.readUint64()
+		if err != nil {
+			return 0, time.Time{}, err
+		}
 
-					toWrite = int32(binary.LittleEndian.Uint32(buf))
+		ts = time.Unix(0, int64(nanos))
+	}
 
-				// uints
-				case reflect.Uint:
-					fallthrough
-				case reflect.Uint64:
-					if _, err := io.ReadAtLeast(r.r, longbuf, len(longbuf)); err != nil {
-						return err
-					}
+	logger, ok := r.loggers[id]
+	if !ok {
+		return 0, time.Time{}, r.unknownHandle(id)
+	}
 
-					toWrite = binary.LittleEndian.Uint64(longbuf)
+	for _, k := range logger.Kinds {
+		if err := r.skipValue(k); err != nil {
+			return 0, time.Time{}, err
+		}
+	}
 
-				case reflect.Uint8:
-					b, err := r.r.ReadByte()
-					if err != nil {
-						return err
-					}
+	return id, ts, nil
+}
 
-					toWrite = uint8(b)
+// sizes of the encoded data for each kind, except strings which vary
+var kindSizes = map[reflect.Kind]int{
+	reflect.Bool:       1,
+	reflect.Int:        8,
+	reflect.Int8:       1,
+	reflect.Int16:      2,
+	reflect.Int32:      4,
+	reflect.Int64:      8,
+	reflect.Uint:       8,
+	reflect.Uint8:      1,
+	reflect.Uint16:     2,
+	reflect.Uint32:     4,
+	reflect.Uint64:     8,
+	reflect.Float32:    4,
+	reflect.Float64:    8,
+	reflect.Complex64:  8,
+	reflect.Complex128: 16,
+}
 
-				case reflect.Uint16:
-					if _, err := io.ReadAtLeast(r.r, smallbuf, len(smallbuf)); err != nil {
-						return err
-					}
+// skipValue moves past a single value of the given kind without decoding it
+func (r *Reader) skipValue(k reflect.Kind) error {
+	if k == reflect.String {
+		strlen, err := r.readUint32()
+		if err != nil {
+			return err
+		}
 
-					toWrite = binary.LittleEndian.Uint16(smallbuf)
+		return r.discard(int(strlen))
+	}
 
-				case reflect.Uint32:
-					if _, err := io.ReadAtLeast(r.r, buf, len(buf)); err != nil {
-						return err
-					}
+	size, ok := kindSizes[k]
+	if !ok {
+		return fmt.Errorf("Invalid Kind in logger: %v", k)
+	}
 
-					toWrite = binary.LittleEndian.Uint32(buf)
+	return r.discard(size)
+}
 
-				// floats
-				case reflect.Float32:
-					if _, err := io.ReadAtLeast(r.r, buf, len(buf)); err != nil {
-						return err
-					}
+// readValue decodes a single value of the given kind from the input
+func (r *Reader) readValue(k reflect.Kind) (interface{}, error) {
+	switch k {
+	case reflect.Bool:
+		b, err := r.readByte()
+		if err != nil {
+			return nil, err
+		}
 
-					toWrite = math.Float32frombits(binary.LittleEndian.Uint32(buf))
+		return b != 0, nil
 
-				case reflect.Float64:
-					if _, err := io.ReadAtLeast(r.r, longbuf, len(longbuf)); err != nil {
-						return err
-					}
+	case reflect.String:
+		return r.readString()
 
-					toWrite = math.Float64frombits(binary.LittleEndian.Uint64(longbuf))
+	// ints
+	case reflect.Int:
+		// generic int is always written as 64 bits
+		i, err := r.readUint64()
+		return int(int64(i)), err
 
-				// complex
-				case reflect.Complex64:
-					if _, err := io.ReadAtLeast(r.r, buf, len(buf)); err != nil {
-						return err
-					}
+	case reflect.Int8:
+		b, err := r.readByte()
+		return int8(b), err
 
-					real := math.Float32frombits(binary.LittleEndian.Uint32(buf))
+	case reflect.Int16:
+		i, err := r.readUint16()
+		return int16(i), err
 
-					if _, err := io.ReadAtLeast(r.r, buf, len(buf)); err != nil {
-						return err
-					}
+	case reflect.Int32:
+		i, err := r.readUint32()
+		return int32(i), err
 
-					imag := math.Float32frombits(binary.LittleEndian.Uint32(buf))
+	case reflect.Int64:
+		i, err := r.readUint64()
+		return int64(i), err
 
-					toWrite = complex(real, imag)
+	// uints
+	case reflect.Uint:
+		// generic uint is always written as 64 bits
+		i, err := r.readUint64()
+		return uint(i), err
 
-				case reflect.Complex128:
-					if _, err := io.ReadAtLeast(r.r, longbuf, len(longbuf)); err != nil {
-						return err
-					}
+	case reflect.Uint8:
+		b, err := r.readByte()
+		return uint8(b), err
 
-					real := math.Float64frombits(binary.LittleEndian.Uint64(longbuf))
+	case reflect.Uint16:
+		return r.readUint16()
 
-					if _, err := io.ReadAtLeast(r.r, longbuf, len(longbuf)); err != nil {
-						return err
-					}
+	case reflect.Uint32:
+		return r.readUint32()
 
-					imag := math.Float64frombits(binary.LittleEndian.Uint64(longbuf))
+	case reflect.Uint64:
+		return r.readUint64()
 
-					toWrite = complex(real, imag)
+	// floats
+	case reflect.Float32:
+		i, err := r.readUint32()
+		return math.Float32frombits(i), err
 
-				default:
-					return fmt.Errorf("Invalid Kind in logger: %v", logger.Kinds[i-1])
-				}
+	case reflect.Float64:
+		i, err := r.readUint64()
+		return math.Float64frombits(i), err
 
-				if toWrite != nil {
-					_, err = fmt.Fprint(r.w, toWrite)
-					if err != nil {
-						return err
-					}
-				}
+	// complex
+	case reflect.Complex64:
+		re, err := r.readUint32()
+		if err != nil {
+			return nil, err
+		}
 
-				r.w.WriteString(logger.Segs[i])
-			}
+		im, err := r.readUint32()
+		if err != nil {
+			return nil, err
+		}
 
-			r.w.WriteByte('\n')
+		return complex(math.Float32frombits(re), math.Float32frombits(im)), nil
 
-		default:
-			return errors.New("Bad file format")
+	case reflect.Complex128:
+		re, err := r.readUint64()
+		if err != nil {
+			return nil, err
+		}
+
+		im, err := r.readUint64()
+		if err != nil {
+			return nil, err
 		}
+
+		return complex(math.Float64frombits(re), math.Float64frombits(im)), nil
 	}
 
-	r.w.Flush()
+	return nil, fmt.Errorf("Invalid Kind in logger: %v", k)
+}
 
-	return nil
+func (r *Reader) readByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	r.off++
+	if r.sum != nil {
+		r.sum.Write([]byte{b})
+	}
+	return b, nil
+}
+
+func (r *Reader) discard(n int) error {
+	if r.sum != nil {
+		copied, err := io.CopyN(r.sum, r.r, int64(n))
+		r.off += copied
+		return err
+	}
+
+	n, err := r.r.Discard(n)
+	r.off += int64(n)
+	return err
+}
+
+func (r *Reader) readFull(buf []byte) error {
+	n, err := io.ReadFull(r.r, buf)
+	r.off += int64(n)
+	if r.sum != nil {
+		r.sum.Write(buf[:n])
+	}
+	return err
+}
+
+func (r *Reader) readUint16() (uint16, error) {
+	if err := r.readFull(r.buf[:2]); err != nil {
+		return 0, err
+	}
+
+	return binary.LittleEndian.Uint16(r.buf[:2]), nil
+}
+
+func (r *Reader) readUint32() (uint32, error) {
+	if err := r.readFull(r.buf[:4]); err != nil {
+		return 0, err
+	}
+
+	return binary.LittleEndian.Uint32(r.buf[:4]), nil
+}
+
+func (r *Reader) readUint64() (uint64, error) {
+	if err := r.readFull(r.buf[:8]); err != nil {
+		return 0, err
+	}
+
+	return binary.LittleEndian.Uint64(r.buf[:8]), nil
+}
+
+func (r *Reader) readString() (string, error) {
+	strlen, err := r.readUint32()
+	if err != nil {
+		return "", err
+	}
+
+	sb := &strings.Builder{}
+	var w io.Writer = sb
+	if r.sum != nil {
+		w = io.MultiWriter(sb, r.sum)
+	}
+
+	n, err := io.CopyN(w, r.r, int64(strlen))
+	r.off += n
+	if err != nil {
+		return "", err
+	}
+
+	return sb.String(), nil
+}
+
+// noEOF converts an io.EOF in the middle of a record to io.ErrUnexpectedEOF
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
 }
@@ -17,9 +17,11 @@ package reader
 import (
 	"bytes"
 	"fmt"
-	"testing"
-
 	"io"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
 
 	"github.com/ScottMansfield/nanolog"
 )
@@ -82,3 +84,153 @@ func TestReaderEarlyExits(t *testing.T) {
 		})
 	}
 }
+
+func TestReaderNext(t *testing.T) {
+	inbuf := &bytes.Buffer{}
+	lw := nanolog.New()
+	lw.SetWriter(inbuf)
+
+	h1 := lw.AddLogger("first %s %i")
+	h2 := lw.AddLogger("second %u16 %f64 %b")
+	lw.Log(h1, "foo", 42)
+	lw.Log(h2, uint16(7), 1.5, true)
+	lw.Flush()
+
+	r := New(inbuf, &bytes.Buffer{})
+
+	rec, err := r.Next()
+	if err != nil {
+		t.Fatalf("Got error reading first record: %v", err)
+	}
+	if rec.Handle != h1 {
+		t.Fatalf("Expected handle %v but got %v", h1, rec.Handle)
+	}
+	if !reflect.DeepEqual(rec.Args, []interface{}{"foo", 42}) {
+		t.Fatalf("Unexpected args: %#v", rec.Args)
+	}
+	if msg := rec.Message(); msg != "first foo 42" {
+		t.Fatalf("Unexpected message: %q", msg)
+	}
+
+	rec, err = r.Next()
+	if err != nil {
+		t.Fatalf("Got error reading second record: %v", err)
+	}
+	if rec.Handle != h2 {
+		t.Fatalf("Expected handle %v but got %v", h2, rec.Handle)
+	}
+	if !reflect.DeepEqual(rec.Kinds, []reflect.Kind{reflect.Uint16, reflect.Float64, reflect.Bool}) {
+		t.Fatalf("Unexpected kinds: %v", rec.Kinds)
+	}
+	if !reflect.DeepEqual(rec.Args, []interface{}{uint16(7), 1.5, true}) {
+		t.Fatalf("Unexpected args: %#v", rec.Args)
+	}
+
+	if _, err := r.Next(); err != io.EOF {
+		t.Fatalf("Expected io.EOF at end of input but got %v", err)
+	}
+}
+
+func TestReaderUnknownHandle(t *testing.T) {
+	// an entry for handle 3 with no log line record before it
+	in := []byte{byte(nanolog.ETLogEntry), 3, 0, 0, 0}
+
+	r := New(bytes.NewReader(in), &bytes.Buffer{})
+	if _, err := r.Next(); err == nil {
+		t.Fatalf("Expected an error for an unknown handle")
+	}
+}
+
+func TestReaderModifiers(t *testing.T) {
+	inbuf := &bytes.Buffer{}
+	lw := nanolog.New()
+	lw.SetWriter(inbuf)
+
+	h := lw.AddLogger("addr %{u32:#x} took %{f64:.3}s, attempt %{i:03} of %i")
+	lw.Log(h, uint32(48879), 1.23456, 7, 10)
+	lw.Flush()
+
+	outbuf := &bytes.Buffer{}
+	if err := New(inbuf, outbuf).Inflate(); err != nil {
+		t.Fatalf("Got error during inflate: %v", err)
+	}
+
+	expected := "addr 0xbeef took 1.235s, attempt 007 of 10\n"
+	if out := outbuf.String(); out != expected {
+		t.Fatalf("Expected %q but got %q", expected, out)
+	}
+}
+
+func TestReaderSourceLocation(t *testing.T) {
+	inbuf := &bytes.Buffer{}
+	lw := nanolog.New(nanolog.WithSourceLocations(true))
+	lw.SetWriter(inbuf)
+
+	_, file, line, _ := runtime.Caller(0)
+	h := lw.AddLogger("located %i")
+	lw.Log(h, 1)
+	lw.Flush()
+
+	rec, err := New(inbuf, &bytes.Buffer{}).Next()
+	if err != nil {
+		t.Fatalf("Got error reading record: %v", err)
+	}
+
+	fn := "github.com/ScottMansfield/nanolog/reader.TestReaderSourceLocation"
+	if rec.File != file || rec.Line != line+1 || rec.Func != fn {
+		t.Fatalf("Expected location %s:%d in %s but got %s:%d in %s", file, line+1, fn, rec.File, rec.Line, rec.Func)
+	}
+
+	js, err := rec.MarshalJSON()
+	if err != nil {
+		t.Fatalf("Got error marshalling record: %v", err)
+	}
+
+	expected := fmt.Sprintf(`"file":%q,"line":%d,"func":%q}`, file, line+1, fn)
+	if !strings.HasSuffix(string(js), expected) {
+		t.Fatalf("Expected JSON to end with %s but got %s", expected, js)
+	}
+}
+
+func TestReaderName(t *testing.T) {
+	inbuf := &bytes.Buffer{}
+	lw := nanolog.New()
+	lw.SetWriter(inbuf)
+
+	h := lw.AddNamedLogger("startup", "started %s")
+	lw.Log(h, "now")
+	lw.Flush()
+
+	rec, err := New(inbuf, &bytes.Buffer{}).Next()
+	if err != nil {
+		t.Fatalf("Got error reading record: %v", err)
+	}
+
+	if rec.Name != "startup" || rec.Message() != "started now" {
+		t.Fatalf("Expected the named log line but got name %q and message %q", rec.Name, rec.Message())
+	}
+}
+
+func TestReaderLevel(t *testing.T) {
+	inbuf := &bytes.Buffer{}
+	lw := nanolog.New()
+	lw.SetWriter(inbuf)
+
+	hInfo := lw.AddLogger("info %i")
+	hDebug := lw.AddLeveledLogger(nanolog.LevelDebug, "debug %i")
+	lw.Log(hInfo, 1)
+	lw.Log(hDebug, 2)
+	lw.Flush()
+
+	r := New(inbuf, &bytes.Buffer{})
+
+	for _, expected := range []nanolog.Level{nanolog.LevelInfo, nanolog.LevelDebug} {
+		rec, err := r.Next()
+		if err != nil {
+			t.Fatalf("Got error reading record: %v", err)
+		}
+		if rec.Level != expected {
+			t.Fatalf("Expected level %v but got %v", expected, rec.Level)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reader
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ScottMansfield/nanolog"
+)
+
+// ErrNotRing is returned by RecoverRing for a file that isn't a ring file
+var ErrNotRing = errors.New("Not a ring file")
+
+// RingRecovery describes what RecoverRing got out of a ring file
+type RingRecovery struct {
+	// LineBytes is the size of the log line records
+	LineBytes int64 `json:"lineBytes"`
+	// Records is the number of entries, and any other records, recovered
+	// from the ring, oldest first
+	Records uint64 `json:"records"`
+	// Overwritten is the number of bytes of entries that were overwritten by
+	// newer ones
+	Overwritten uint64 `json:"overwritten"`
+	// Damage describes where recovery had to stop early, if it did
+	Damage string `json:"damage,omitempty"`
+}
+
+// RecoverRing writes the log kept in a ring file (see nanolog.OpenRing) to w,
+// as a normal log of the log line records followed by the entries still in the
+// ring in the order they were logged. Recovery stops at the first record that
+// doesn't make sense, keeping what came before it.
+func RecoverRing(data []byte, w io.Writer) (*RingRecovery, error) {
+	if len(data) < nanolog.RingHeaderLen || string(data[:8]) != nanolog.RingMagic {
+		return nil, ErrNotRing
+	}
+	if v := binary.LittleEndian.Uint32(data[8:]); v != 1 {
+		return nil, fmt.Errorf("Unknown ring file version %d", v)
+	}
+
+	u64 := func(off int) uint64 {
+		return binary.LittleEndian.Uint64(data[off:])
+	}
+	linesCap, ringCap := u64(16), u64(24)
+	used, head, tail := u64(32), u64(40), u64(48)
+
+	if linesCap+ringCap != uint64(len(data)-nanolog.RingHeaderLen) || ringCap == 0 {
+		return nil, fmt.Errorf("Ring file is %d bytes but its header says %d", len(data), nanolog.RingHeaderLen+linesCap+ringCap)
+	}
+
+	rec := &RingRecovery{Overwritten: tail}
+
+	if used > linesCap {
+		rec.Damage = fmt.Sprintf("%d bytes of log lines don't fit in %d", used, linesCap)
+		used = linesCap
+	}
+
+	lines := data[nanolog.RingHeaderLen : nanolog.RingHeaderLen+linesCap]
+	ring := data[nanolog.RingHeaderLen+linesCap:]
+
+	if _, err := w.Write(lines[:used]); err != nil {
+		return nil, err
+	}
+	rec.LineBytes = int64(used)
+
+	if tail > head || head-tail > ringCap {
+		rec.Damage = fmt.Sprintf("Ring head %d and tail %d are inconsistent", head, tail)
+		return rec, nil
+	}
+
+	// read from the ring at a position, wrapping around its end
+	readAt := func(pos uint64, b []byte) {
+		n := copy(b, ring[pos%ringCap:])
+		copy(b[n:], ring)
+	}
+
+	var lenbuf [4]byte
+	var buf []byte
+
+	for pos := tail; pos < head; {
+		if head-pos < 4 {
+			rec.Damage = fmt.Sprintf("Partial record length at ring position %d", pos)
+			break
+		}
+
+		readAt(pos, lenbuf[:])
+		n := uint64(binary.LittleEndian.Uint32(lenbuf[:]))
+		if n == 0 || n > head-pos-4 {
+			rec.Damage = fmt.Sprintf("Bad record length %d at ring position %d", n, pos)
+			break
+		}
+
+		if uint64(cap(buf)) < n {
+			buf = make([]byte, n)
+		}
+		buf = buf[:n]
+		readAt(pos+4, buf)
+
+		if _, err := w.Write(buf); err != nil {
+			return nil, err
+		}
+
+		rec.Records++
+		pos += 4 + n
+	}
+
+	return rec, nil
+}
@@ -0,0 +1,161 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/ScottMansfield/nanolog"
+)
+
+func TestRecoverRing(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Ring files are only supported on linux")
+	}
+
+	dir, err := ioutil.TempDir("", "nanolog")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "log.ring")
+
+	// 1 byte type, 4 byte handle and an int, plus the length
+	const entryLen = 4 + 13
+
+	r, err := nanolog.OpenRing(path, 1024, 5*entryLen)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	lw := nanolog.New(nanolog.WithRing(r))
+	h := lw.AddLogger("ring %i")
+	for i := 0; i < 12; i++ {
+		lw.Log(h, i)
+	}
+	// no Close, like a killed process
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	out := &bytes.Buffer{}
+	rec, err := RecoverRing(data, out)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if rec.Records != 5 || rec.Overwritten != 7*entryLen || rec.Damage != "" {
+		t.Fatalf("Unexpected recovery %+v", rec)
+	}
+
+	text := &bytes.Buffer{}
+	if err := New(out, text).Inflate(); err != nil {
+		t.Fatalf("Unexpected error inflating the recovered log: %v", err)
+	}
+	if expected := "ring 7\nring 8\nring 9\nring 10\nring 11\n"; text.String() != expected {
+		t.Fatalf("Expected %q but got %q", expected, text.String())
+	}
+
+	t.Run("Damaged", func(t *testing.T) {
+		bad := append([]byte{}, data...)
+		// make the length of the third entry too long
+		tail := binary.LittleEndian.Uint64(bad[48:])
+		pos := nanolog.RingHeaderLen + 1024 + (tail+2*entryLen)%(5*entryLen)
+		binary.LittleEndian.PutUint32(bad[pos:], 1000)
+
+		rec, err := RecoverRing(bad, ioutil.Discard)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if rec.Records != 2 || rec.Damage == "" {
+			t.Fatalf("Expected recovery to stop after 2 records but got %+v", rec)
+		}
+	})
+
+	t.Run("NotRing", func(t *testing.T) {
+		if _, err := RecoverRing([]byte("not a ring file at all, really not at all, no ring here at all"), ioutil.Discard); err != ErrNotRing {
+			t.Fatalf("Expected ErrNotRing but got %v", err)
+		}
+	})
+}
+
+func TestRecoverRingTrailer(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Ring files are only supported on linux")
+	}
+
+	dir, err := ioutil.TempDir("", "nanolog")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// 1 byte type, 4 byte handle and an int, plus the length
+	const entryLen = 4 + 13
+
+	for _, tc := range []struct {
+		name    string
+		entries int
+	}{
+		{"Clean", 2},
+		// the ring has room for 5 entries and the trailer overwrites 2 more
+		{"Wrapped", 12},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, tc.name+".ring")
+			r, err := nanolog.OpenRing(path, 1024, 5*entryLen)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			lw := nanolog.New(nanolog.WithTrailer(true), nanolog.WithRing(r))
+			// log lines interleaved with entries end up ahead of them
+			h1 := lw.AddLogger("first %i")
+			lw.Log(h1, 1)
+			h2 := lw.AddLogger("second %i")
+			for i := 1; i < tc.entries; i++ {
+				lw.Log(h2, i)
+			}
+			if err := lw.Close(); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			out := &bytes.Buffer{}
+			if _, err := RecoverRing(data, out); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			v, err := New(out, ioutil.Discard).Verify()
+			if err != nil {
+				t.Fatalf("Expected the recovered log to verify but got %v", err)
+			}
+			if v.Trailers != 1 || !v.Closed {
+				t.Fatalf("Expected the trailer to be checked but got %+v", v)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reader
+
+import (
+	"io"
+	"sort"
+
+	"github.com/ScottMansfield/nanolog"
+)
+
+// Schema reads the rest of the input and describes the log lines defined in
+// it, in handle order. Entries are skipped without being decoded. An index
+// file holds a copy of every log line in the log, so its schema can be read
+// much faster than the log's.
+func (r *Reader) Schema() ([]nanolog.LineSchema, error) {
+	for {
+		rawType, err := r.readByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if err := r.skipRecord(rawType); err != nil {
+			return nil, noEOF(err)
+		}
+	}
+
+	schema := make([]nanolog.LineSchema, 0, len(r.loggers))
+	for id, l := range r.loggers {
+		schema = append(schema, nanolog.NewLineSchema(nanolog.Handle(id), l))
+	}
+
+	sort.Slice(schema, func(i, j int) bool { return schema[i].Handle < schema[j].Handle })
+
+	return schema, nil
+}
@@ -0,0 +1,46 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reader
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/ScottMansfield/nanolog"
+)
+
+func TestSchema(t *testing.T) {
+	buf := &bytes.Buffer{}
+	lw := nanolog.New(nanolog.WithSourceLocations(true))
+	lw.SetWriter(buf)
+
+	h1 := lw.AddLogger("before %s")
+	lw.Log(h1, "skipped")
+	// log lines can be added after entries have been logged
+	h2 := lw.AddLogger("after %{u8:02} %c64")
+	lw.Log(h2, uint8(1), complex64(2i))
+	lw.Log(h1, "also skipped")
+	lw.Flush()
+
+	schema, err := New(buf, &bytes.Buffer{}).Schema()
+	if err != nil {
+		t.Fatalf("Got error reading schema: %v", err)
+	}
+
+	if expected := lw.Schema(); !reflect.DeepEqual(schema, expected) {
+		t.Fatalf("Expected the schema to match the writer's.\nExpected: %+v\nGot: %+v", expected, schema)
+	}
+}
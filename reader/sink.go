@@ -0,0 +1,61 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reader
+
+import (
+	"bytes"
+	"io"
+	"text/template"
+)
+
+// TextSink renders a log as it is being written, for use as the writer of a
+// live nanolog.Sink. Each Write must hold whole records, which a live sink
+// makes sure of. The entries are written out like Inflate, or with a template,
+// and the output is flushed after every Write.
+type TextSink struct {
+	in    bytes.Buffer
+	r     *Reader
+	write func(Record) error
+}
+
+// NewTextSink creates a TextSink that writes each entry to w as its message
+// followed by a newline, the same as Inflate
+func NewTextSink(w io.Writer) *TextSink {
+	s := &TextSink{}
+	s.r = New(&s.in, w)
+	s.write = textWriter(s.r.w)
+	return s
+}
+
+// NewTemplateSink creates a TextSink that executes the template for each entry
+// and writes the output followed by a newline to w, the same as
+// InflateTemplate
+func NewTemplateSink(w io.Writer, tmpl *template.Template) *TextSink {
+	s := &TextSink{}
+	s.r = New(&s.in, w)
+	s.write = templateWriter(s.r.w, tmpl)
+	return s
+}
+
+// Write decodes the records in p and writes out the entries among them
+func (s *TextSink) Write(p []byte) (int, error) {
+	s.in.Write(p)
+
+	if err := inflate(s.r.Next, s.r.w, s.write); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
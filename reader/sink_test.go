@@ -0,0 +1,74 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reader
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ScottMansfield/nanolog"
+)
+
+func TestTextSink(t *testing.T) {
+	bin := &bytes.Buffer{}
+	text := &bytes.Buffer{}
+	lw := nanolog.NewTee([]nanolog.Sink{
+		{W: bin, Level: nanolog.LevelDebug},
+		{W: NewTextSink(text), Level: nanolog.LevelWarn, Live: true},
+	})
+
+	hDebug := lw.AddLeveledLogger(nanolog.LevelDebug, "debug %i")
+	hWarn := lw.AddLeveledLogger(nanolog.LevelWarn, "warn %s")
+
+	lw.Log(hDebug, 1)
+	lw.Log(hWarn, "foo")
+
+	// rendered as soon as it is logged
+	if text.String() != "warn foo\n" {
+		t.Fatalf("Expected only the warning but got %q", text.String())
+	}
+
+	lw.Log(hWarn, "bar")
+	if text.String() != "warn foo\nwarn bar\n" {
+		t.Fatalf("Expected both warnings but got %q", text.String())
+	}
+
+	lw.Flush()
+
+	out := &bytes.Buffer{}
+	if err := New(bin, out).Inflate(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if out.String() != "debug 1\nwarn foo\nwarn bar\n" {
+		t.Fatalf("Expected the whole log in the binary sink but got %q", out.String())
+	}
+}
+
+func TestTemplateSink(t *testing.T) {
+	tmpl, err := NewTemplate(`{{.Level}}: {{.Message}}`)
+	if err != nil {
+		t.Fatalf("Got error parsing template: %v", err)
+	}
+
+	text := &bytes.Buffer{}
+	lw := nanolog.NewTee([]nanolog.Sink{{W: NewTemplateSink(text, tmpl), Live: true}})
+
+	h := lw.AddLeveledLogger(nanolog.LevelError, "failed after %i tries")
+	lw.Log(h, 3)
+
+	if text.String() != "error: failed after 3 tries\n" {
+		t.Fatalf("Expected the entry rendered with the template but got %q", text.String())
+	}
+}
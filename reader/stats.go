@@ -0,0 +1,168 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reader
+
+import (
+	"io"
+	"sort"
+	"time"
+
+	"github.com/ScottMansfield/nanolog"
+)
+
+// Stats summarizes the contents of a log
+type Stats struct {
+	// Size is the total size of the log in bytes
+	Size int64 `json:"size"`
+	// Entries is the total number of log entries
+	Entries uint64 `json:"entries"`
+	// LineBytes is the size of all the log line records, including any
+	// reference to a dictionary
+	LineBytes int64 `json:"lineBytes"`
+	// First and Last are the times of the earliest and latest entries. They
+	// are zero if the log has no timestamps.
+	First time.Time `json:"first"`
+	Last  time.Time `json:"last"`
+	// Handles has the statistics for each log line, largest first
+	Handles []*HandleStats `json:"handles"`
+}
+
+// HandleStats summarizes the entries for a single log line
+type HandleStats struct {
+	Handle nanolog.Handle `json:"handle"`
+	Format string         `json:"format"`
+	// Entries is the number of entries for the log line
+	Entries uint64 `json:"entries"`
+	// Bytes is the total size of the log line record and all of the entries
+	Bytes int64 `json:"bytes"`
+	// EntryBytes is the size of the entries alone
+	EntryBytes int64 `json:"entryBytes"`
+	// First and Last are the times of the earliest and latest entries
+	First time.Time `json:"first"`
+	Last  time.Time `json:"last"`
+	// Share is the fraction of the log's size taken by Bytes
+	Share float64 `json:"share"`
+}
+
+// AvgEntryBytes is the average size of an entry for the log line
+func (hs *HandleStats) AvgEntryBytes() float64 {
+	if hs.Entries == 0 {
+		return 0
+	}
+	return float64(hs.EntryBytes) / float64(hs.Entries)
+}
+
+// Stats reads the rest of the input and summarizes it. The entries are only
+// measured, not decoded, so this is much faster than inflating the log.
+func (r *Reader) Stats() (*Stats, error) {
+	s := &Stats{}
+	handles := make(map[uint32]*HandleStats)
+
+	stats := func(id uint32) *HandleStats {
+		hs, ok := handles[id]
+		if !ok {
+			hs = &HandleStats{Handle: nanolog.Handle(id)}
+			handles[id] = hs
+		}
+		return hs
+	}
+
+	start := r.off
+
+	for {
+		off := r.off
+
+		rawType, err := r.readByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch nanolog.EntryType(rawType) {
+		case nanolog.ETLogLine:
+			id, err := r.readLogLine()
+			if err != nil {
+				return nil, noEOF(err)
+			}
+
+			hs := stats(id)
+			hs.Format = r.loggers[id].Format()
+			hs.Bytes += r.off - off
+			s.LineBytes += r.off - off
+
+		case nanolog.ETLogEntry, nanolog.ETTimedLogEntry:
+			id, ts, err := r.skipLogEntry(nanolog.EntryType(rawType) == nanolog.ETTimedLogEntry)
+			if err != nil {
+				return nil, noEOF(err)
+			}
+
+			hs := stats(id)
+			hs.Entries++
+			hs.Bytes += r.off - off
+			hs.EntryBytes += r.off - off
+			s.Entries++
+
+			if !ts.IsZero() {
+				if hs.First.IsZero() || ts.Before(hs.First) {
+					hs.First = ts
+				}
+				if ts.After(hs.Last) {
+					hs.Last = ts
+				}
+				if s.First.IsZero() || ts.Before(s.First) {
+					s.First = ts
+				}
+				if ts.After(s.Last) {
+					s.Last = ts
+				}
+			}
+
+		case nanolog.ETDictionary:
+			if err := r.skipRecord(rawType); err != nil {
+				return nil, noEOF(err)
+			}
+			s.LineBytes += r.off - off
+
+		default:
+			if err := r.skipRecord(rawType); err != nil {
+				return nil, noEOF(err)
+			}
+		}
+	}
+
+	s.Size = r.off - start
+
+	for id, hs := range handles {
+		// log lines from a dictionary have no record in the log
+		if hs.Format == "" {
+			hs.Format = r.loggers[id].Format()
+		}
+		if s.Size > 0 {
+			hs.Share = float64(hs.Bytes) / float64(s.Size)
+		}
+		s.Handles = append(s.Handles, hs)
+	}
+
+	sort.Slice(s.Handles, func(i, j int) bool {
+		if s.Handles[i].Bytes != s.Handles[j].Bytes {
+			return s.Handles[i].Bytes > s.Handles[j].Bytes
+		}
+		return s.Handles[i].Handle < s.Handles[j].Handle
+	})
+
+	return s, nil
+}
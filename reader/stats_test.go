@@ -0,0 +1,90 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reader
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/ScottMansfield/nanolog"
+)
+
+func TestStats(t *testing.T) {
+	buf := &bytes.Buffer{}
+	lw := nanolog.New(nanolog.WithTimestamps(true))
+	lw.SetWriter(buf)
+
+	hSmall := lw.AddLogger("small %u8")
+	hBig := lw.AddLogger("big %s")
+	hUnused := lw.AddLogger("unused")
+
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		lw.Log(hSmall, uint8(i))
+	}
+	lw.Log(hBig, "a long string that takes up a lot of space")
+	lw.Log(hBig, "short")
+	end := time.Now()
+	lw.Flush()
+
+	size := int64(buf.Len())
+
+	s, err := New(buf, &bytes.Buffer{}).Stats()
+	if err != nil {
+		t.Fatalf("Got error getting stats: %v", err)
+	}
+
+	if s.Size != size || s.Entries != 12 {
+		t.Fatalf("Expected size %d and 12 entries but got %d and %d", size, s.Size, s.Entries)
+	}
+	if s.First.Before(start) || s.Last.After(end) || s.Last.Before(s.First) {
+		t.Fatalf("Unexpected first and last times %v and %v", s.First, s.Last)
+	}
+	if len(s.Handles) != 3 {
+		t.Fatalf("Expected stats for 3 handles but got %d", len(s.Handles))
+	}
+
+	// 1 type + 4 id + 8 timestamp, then the data
+	small := int64(10 * (13 + 1))
+	big := int64(2*(13+4) + len("a long string that takes up a lot of space") + len("short"))
+
+	var total int64
+	for _, hs := range s.Handles {
+		total += hs.Bytes
+
+		switch hs.Handle {
+		case hSmall:
+			if hs.Entries != 10 || hs.EntryBytes != small || hs.AvgEntryBytes() != 14 || hs.Format != "small %u8" {
+				t.Fatalf("Unexpected stats for the small handle: %+v", hs)
+			}
+		case hBig:
+			if hs.Entries != 2 || hs.EntryBytes != big {
+				t.Fatalf("Unexpected stats for the big handle: %+v", hs)
+			}
+		case hUnused:
+			if hs.Entries != 0 || hs.Bytes == 0 || !hs.First.IsZero() {
+				t.Fatalf("Unexpected stats for the unused handle: %+v", hs)
+			}
+		}
+	}
+
+	if total != size {
+		t.Fatalf("Expected the handles to add up to the size %d but got %d", size, total)
+	}
+	if s.Handles[0].Handle != hSmall {
+		t.Fatalf("Expected the largest handle first but got %v", s.Handles[0].Handle)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reader
+
+import (
+	"bufio"
+	"fmt"
+	"path"
+	"reflect"
+	"text/template"
+)
+
+// kinds by name, e.g. "float64", for use in templates
+var kindsByName = make(map[string]reflect.Kind)
+
+func init() {
+	for k := reflect.Invalid; k <= reflect.UnsafePointer; k++ {
+		kindsByName[k.String()] = k
+	}
+}
+
+var templateFuncs = template.FuncMap{
+	"render": renderFunc,
+	"base":   path.Base,
+}
+
+// renderFunc backs the render template function. The arguments after the
+// record are pairs of kind name and fmt verb.
+func renderFunc(rec Record, pairs ...string) (string, error) {
+	if len(pairs)%2 != 0 {
+		return "", fmt.Errorf("render needs pairs of kind and verb, got %d arguments", len(pairs))
+	}
+
+	verbs := make(map[reflect.Kind]string, len(pairs)/2)
+
+	for i := 0; i < len(pairs); i += 2 {
+		k, ok := kindsByName[pairs[i]]
+		if !ok {
+			return "", fmt.Errorf("Unknown kind %q", pairs[i])
+		}
+
+		verbs[k] = pairs[i+1]
+	}
+
+	return rec.Render(verbs), nil
+}
+
+// NewTemplate parses text into a template that can be used with
+// InflateTemplate. The template is executed with each Record in turn, so it can
+// refer to fields like .Time, .Handle, .Level, .Offset, .Source, .File, .Line and .Args
+// and to methods like .Message and .Format. In addition to the text/template
+// builtins there is a render function that formats the message with fmt verbs
+// for specific kinds, and a base function that returns the last element of a
+// path:
+//
+//	{{.Time.Format "15:04:05.000"}} [{{.Handle}}] {{render . "float64" "%.3f"}}
+//	{{base .File}}:{{.Line}}: {{.Message}}
+func NewTemplate(text string) (*template.Template, error) {
+	return template.New("record").Funcs(templateFuncs).Parse(text)
+}
+
+// InflateTemplate will read from the supplied reader and execute the template
+// for each log entry, writing the output followed by a newline to the supplied
+// writer.
+func (r *Reader) InflateTemplate(tmpl *template.Template) error {
+	return inflate(r.Next, r.w, templateWriter(r.w, tmpl))
+}
+
+func templateWriter(w *bufio.Writer, tmpl *template.Template) func(Record) error {
+	return func(rec Record) error {
+		if err := tmpl.Execute(w, rec); err != nil {
+			return err
+		}
+
+		return w.WriteByte('\n')
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reader
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ScottMansfield/nanolog"
+)
+
+func TestInflateTemplate(t *testing.T) {
+	inbuf := &bytes.Buffer{}
+	lw := nanolog.New(nanolog.WithTimestamps(true))
+	lw.SetWriter(inbuf)
+
+	h := lw.AddLogger("took %f64 seconds for %s")
+
+	before := time.Now()
+	lw.Log(h, 1.23456, "foo")
+	lw.Flush()
+
+	tmpl, err := NewTemplate(`{{.Handle}}@{{.Offset}} {{render . "float64" "%.2f"}}`)
+	if err != nil {
+		t.Fatalf("Got error parsing template: %v", err)
+	}
+
+	outbuf := &bytes.Buffer{}
+	if err := New(bytes.NewReader(inbuf.Bytes()), outbuf).InflateTemplate(tmpl); err != nil {
+		t.Fatalf("Got error during inflate: %v", err)
+	}
+
+	// the entry comes right after the log line record
+	offset := 1 + 4 + 4 + 2 + 4 + len("took ") + 4 + len(" seconds for ") + 4
+	expected := "0@" + strconv.Itoa(offset) + " took 1.23 seconds for foo\n"
+
+	if out := outbuf.String(); out != expected {
+		t.Fatalf("Expected %q but got %q", expected, out)
+	}
+
+	rec, err := New(bytes.NewReader(inbuf.Bytes()), &bytes.Buffer{}).Next()
+	if err != nil {
+		t.Fatalf("Got error reading record: %v", err)
+	}
+	if rec.Time.Before(before) || rec.Time.After(time.Now()) {
+		t.Fatalf("Unexpected timestamp %v", rec.Time)
+	}
+}
+
+func TestTemplateRenderErrors(t *testing.T) {
+	for _, text := range []string{`{{render . "float64"}}`, `{{render . "float99" "%f"}}`} {
+		tmpl, err := NewTemplate(text)
+		if err != nil {
+			t.Fatalf("Got error parsing template: %v", err)
+		}
+
+		inbuf := &bytes.Buffer{}
+		lw := nanolog.New()
+		lw.SetWriter(inbuf)
+		lw.Log(lw.AddLogger("%f64"), 1.0)
+		lw.Flush()
+
+		err = New(inbuf, &bytes.Buffer{}).InflateTemplate(tmpl)
+		if err == nil || !strings.Contains(err.Error(), "render") {
+			t.Fatalf("Expected an error from render for %q but got %v", text, err)
+		}
+	}
+}
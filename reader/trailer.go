@@ -0,0 +1,107 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reader
+
+import (
+	"fmt"
+
+	"github.com/ScottMansfield/nanolog"
+)
+
+// Trailer is the summary written at the end of a log by a closed LogWriter
+// (see nanolog.WithTrailer)
+type Trailer struct {
+	// Lines and Entries are the number of log line and log entry records
+	Lines   uint64 `json:"lines"`
+	Entries uint64 `json:"entries"`
+	// Size is the number of bytes in the log before the trailer
+	Size int64 `json:"size"`
+	// Checksum is the CRC-32 of those bytes, if HasChecksum is set
+	Checksum    uint32 `json:"checksum"`
+	HasChecksum bool   `json:"hasChecksum"`
+}
+
+// readTrailer reads the rest of a trailer record
+func (r *Reader) readTrailer() (Trailer, error) {
+	var t Trailer
+
+	flags, err := r.readByte()
+	if err != nil {
+		return t, err
+	}
+	if t.Lines, err = r.readUint64(); err != nil {
+		return t, err
+	}
+	if t.Entries, err = r.readUint64(); err != nil {
+		return t, err
+	}
+
+	size, err := r.readUint64()
+	if err != nil {
+		return t, err
+	}
+	t.Size = int64(size)
+
+	if t.Checksum, err = r.readUint32(); err != nil {
+		return t, err
+	}
+	t.HasChecksum = flags&nanolog.TrailerHasChecksum != 0
+
+	return t, nil
+}
+
+// logSpan is the part of the input written by a single LogWriter, as far as
+// Verify can tell: from the start of the input or the end of a trailer up to
+// the next trailer
+type logSpan struct {
+	// offset where the span starts
+	start int64
+	// whether start is known to be the start of a log. It isn't if Verify
+	// started in the middle of the input.
+	whole bool
+	// counts in the Verification when the span started
+	lines, entries uint64
+	// checksum of the span before the record being read
+	sum uint32
+}
+
+// checkTrailer compares the trailer at off with what was read since the span
+// started. A trailer that covers more than the span, e.g. because the log
+// was appended to one that was never closed, can't be checked.
+func (r *Reader) checkTrailer(off int64, t Trailer, span logSpan, v *Verification) error {
+	size := off - span.start
+
+	if t.Size > size {
+		if span.whole {
+			return fmt.Errorf("Trailer covers %d bytes but the log has %d", t.Size, size)
+		}
+		return nil
+	}
+	if t.Size < size {
+		return nil
+	}
+
+	if lines := v.Lines - span.lines; t.Lines != lines {
+		return fmt.Errorf("Trailer counts %d log lines but the log has %d", t.Lines, lines)
+	}
+	if entries := v.Entries - span.entries; t.Entries != entries {
+		return fmt.Errorf("Trailer counts %d entries but the log has %d", t.Entries, entries)
+	}
+	if t.HasChecksum && t.Checksum != span.sum {
+		return fmt.Errorf("Trailer checksum %08x doesn't match the log's %08x", t.Checksum, span.sum)
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reader
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/ScottMansfield/nanolog"
+)
+
+// closedLog writes a log with a trailer
+func closedLog(msg string, n int) []byte {
+	buf := &bytes.Buffer{}
+	lw := nanolog.New(nanolog.WithTrailer(true))
+	lw.SetWriter(buf)
+
+	h := lw.AddLogger(msg + " %i")
+	for i := 0; i < n; i++ {
+		lw.Log(h, i)
+	}
+	lw.Close()
+
+	return buf.Bytes()
+}
+
+func TestTrailer(t *testing.T) {
+	good := closedLog("closed", 3)
+
+	t.Run("Inflate", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		if err := New(bytes.NewReader(good), out).Inflate(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if expected := "closed 0\nclosed 1\nclosed 2\n"; out.String() != expected {
+			t.Fatalf("Expected %q but got %q", expected, out.String())
+		}
+	})
+
+	t.Run("Verify", func(t *testing.T) {
+		v, err := New(bytes.NewReader(good), ioutil.Discard).Verify()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if v.Trailers != 1 || !v.Closed {
+			t.Fatalf("Expected a closed log but got %+v", v)
+		}
+	})
+
+	t.Run("Appended", func(t *testing.T) {
+		// a second log appended to the first is checked on its own
+		input := append(append([]byte{}, good...), closedLog("again", 2)...)
+
+		v, err := New(bytes.NewReader(input), ioutil.Discard).Verify()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if v.Trailers != 2 || v.Entries != 5 || !v.Closed {
+			t.Fatalf("Expected two closed logs but got %+v", v)
+		}
+	})
+
+	t.Run("NotClosed", func(t *testing.T) {
+		input := append(append([]byte{}, good...), good[:len(good)-nanolog.TrailerLen]...)
+
+		v, err := New(bytes.NewReader(input), ioutil.Discard).Verify()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if v.Closed {
+			t.Fatalf("Expected the log not to be closed")
+		}
+	})
+
+	t.Run("Damaged", func(t *testing.T) {
+		bad := append([]byte{}, good...)
+		// change a value in the last entry
+		bad[len(bad)-nanolog.TrailerLen-1] ^= 0xFF
+
+		_, err := New(bytes.NewReader(bad), ioutil.Discard).Verify()
+		ve, ok := err.(*VerifyError)
+		if !ok {
+			t.Fatalf("Expected a *VerifyError but got %v", err)
+		}
+		if ve.Offset != int64(len(bad)-nanolog.TrailerLen) || !strings.Contains(ve.Err.Error(), "checksum") {
+			t.Fatalf("Expected a checksum error at the trailer but got %v", ve)
+		}
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		// drop the first entry: 1 byte type, 4 byte handle and an int
+		lineLen := len(good) - nanolog.TrailerLen - 3*13
+		bad := append(append([]byte{}, good[:lineLen]...), good[lineLen+13:]...)
+
+		_, err := New(bytes.NewReader(bad), ioutil.Discard).Verify()
+		if err == nil || !strings.Contains(err.Error(), "Trailer covers") {
+			t.Fatalf("Expected a size error but got %v", err)
+		}
+	})
+}
@@ -0,0 +1,189 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reader
+
+import (
+	"fmt"
+	"hash/crc32"
+	"io"
+	"reflect"
+
+	"github.com/ScottMansfield/nanolog"
+)
+
+// MaxStringLen is the longest string Verify accepts in a log entry. Anything
+// longer is taken to be a corrupt length.
+const MaxStringLen = 1 << 30
+
+// Verification counts what Verify found in the input before it stopped
+type Verification struct {
+	// Size is the number of bytes checked
+	Size int64 `json:"size"`
+	// Lines is the number of log line records
+	Lines uint64 `json:"lines"`
+	// Entries is the number of log entry records
+	Entries uint64 `json:"entries"`
+	// IndexPoints is the number of index records
+	IndexPoints uint64 `json:"indexPoints"`
+	// Dictionaries is the number of references to a dictionary
+	Dictionaries uint64 `json:"dictionaries"`
+	// Trailers is the number of trailers
+	Trailers uint64 `json:"trailers"`
+	// Closed is whether the input ends with a trailer, i.e. the LogWriter was
+	// closed cleanly
+	Closed bool `json:"closed"`
+}
+
+// VerifyError is the first problem Verify found in the input
+type VerifyError struct {
+	// Offset is the position in the input of the start of the bad record
+	Offset int64
+	// Err describes the problem
+	Err error
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("Bad record at offset %d: %v", e.Offset, e.Err)
+}
+
+// Verify reads the rest of the input and checks that it can be decoded without
+// producing any output. Every record must have a known type and be complete,
+// every log line must only use valid kinds and every log entry must refer to a
+// log line defined before it, with sane string lengths. A log that refers to a
+// dictionary can only be checked if the dictionary has been set. A trailer must
+// match the counts and checksum of the log before it. If there is a problem
+// the error is a *VerifyError with the offset of the first bad record, and the
+// Verification counts the records before it.
+func (r *Reader) Verify() (*Verification, error) {
+	v := &Verification{}
+	start := r.off
+
+	r.sum = crc32.NewIEEE()
+	defer func() { r.sum = nil }()
+
+	span := logSpan{start: start, whole: start == 0}
+
+	for {
+		off := r.off
+		v.Size = off - start
+		span.sum = r.sum.Sum32()
+
+		rawType, err := r.readByte()
+		if err == io.EOF {
+			return v, nil
+		}
+		if err != nil {
+			return v, &VerifyError{Offset: off, Err: err}
+		}
+
+		var count *uint64
+		v.Closed = false
+
+		switch nanolog.EntryType(rawType) {
+		case nanolog.ETLogLine:
+			err = r.verifyLogLine()
+			count = &v.Lines
+
+		case nanolog.ETLogEntry, nanolog.ETTimedLogEntry:
+			err = r.verifyLogEntry(nanolog.EntryType(rawType) == nanolog.ETTimedLogEntry)
+			count = &v.Entries
+
+		case nanolog.ETIndex:
+			err = r.skipRecord(rawType)
+			count = &v.IndexPoints
+
+		case nanolog.ETDictionary:
+			err = r.skipRecord(rawType)
+			count = &v.Dictionaries
+
+		case nanolog.ETTrailer:
+			var t Trailer
+			if t, err = r.readTrailer(); err == nil {
+				err = r.checkTrailer(off, t, span, v)
+			}
+			count = &v.Trailers
+
+			// anything after it was written by another LogWriter
+			span = logSpan{start: r.off, whole: true, lines: v.Lines, entries: v.Entries}
+			r.sum.Reset()
+			v.Closed = true
+
+		default:
+			err = fmt.Errorf("Unknown record type %d", rawType)
+		}
+
+		if err != nil {
+			return v, &VerifyError{Offset: off, Err: noEOF(err)}
+		}
+
+		*count++
+	}
+}
+
+func (r *Reader) verifyLogLine() error {
+	id, err := r.readLogLine()
+	if err != nil {
+		return err
+	}
+
+	for _, k := range r.loggers[id].Kinds {
+		if _, ok := kindSizes[k]; !ok && k != reflect.String {
+			delete(r.loggers, id)
+			return fmt.Errorf("Invalid Kind in logger: %v", k)
+		}
+	}
+
+	return nil
+}
+
+func (r *Reader) verifyLogEntry(timed bool) error {
+	id, err := r.readUint32()
+	if err != nil {
+		return err
+	}
+
+	if timed {
+		if _, err := r.readUint64(); err != nil {
+			return err
+		}
+	}
+
+	logger, ok := r.loggers[id]
+	if !ok {
+		return r.unknownHandle(id)
+	}
+
+	for _, k := range logger.Kinds {
+		if k != reflect.String {
+			if err := r.skipValue(k); err != nil {
+				return err
+			}
+			continue
+		}
+
+		strlen, err := r.readUint32()
+		if err != nil {
+			return err
+		}
+		if strlen > MaxStringLen {
+			return fmt.Errorf("String of %d bytes is too long", strlen)
+		}
+		if err := r.discard(int(strlen)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
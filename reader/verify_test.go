@@ -0,0 +1,138 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/ScottMansfield/nanolog"
+)
+
+func TestVerify(t *testing.T) {
+	good := &bytes.Buffer{}
+	lw := nanolog.New(nanolog.WithTimestamps(true))
+	lw.SetWriter(good)
+
+	h := lw.AddLogger("verify %i %s")
+	lw.AddLogger("unused %f64")
+	for i := 0; i < 5; i++ {
+		lw.Log(h, i, "abc")
+	}
+	lw.Flush()
+
+	goodLen := int64(good.Len())
+
+	u32 := func(v uint32) []byte {
+		b := make([]byte, 4)
+		binary.LittleEndian.PutUint32(b, v)
+		return b
+	}
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+
+	// a log line with one interpolation of the given kind and empty segments
+	line := func(id uint32, kind byte) []byte {
+		return join([]byte{byte(nanolog.ETLogLine)}, u32(id), u32(2), []byte{kind}, u32(0), u32(0))
+	}
+
+	tests := []struct {
+		name    string
+		input   []byte
+		lines   uint64
+		entries uint64
+		offset  int64
+		msg     string
+	}{
+		{
+			name:    "Good",
+			input:   good.Bytes(),
+			lines:   2,
+			entries: 5,
+			offset:  -1,
+		},
+		{
+			name:    "Truncated",
+			input:   good.Bytes()[:goodLen-2],
+			lines:   2,
+			entries: 4,
+			offset:  goodLen - 2 - (1 + 4 + 8 + 8 + 4 + 3 - 2),
+			msg:     io.ErrUnexpectedEOF.Error(),
+		},
+		{
+			name:    "UnknownType",
+			input:   join(good.Bytes(), []byte{42}),
+			lines:   2,
+			entries: 5,
+			offset:  goodLen,
+			msg:     "Unknown record type 42",
+		},
+		{
+			name:   "UnknownHandle",
+			input:  join(line(0, byte(reflect.Uint8)), []byte{byte(nanolog.ETLogEntry)}, u32(7), []byte{1}),
+			lines:  1,
+			offset: 18,
+			msg:    "Log entry for unknown handle 7",
+		},
+		{
+			name:   "InvalidKind",
+			input:  line(0, 200),
+			offset: 0,
+			msg:    "Invalid Kind in logger: kind200",
+		},
+		{
+			name:   "LongString",
+			input:  join(line(3, byte(reflect.String)), []byte{byte(nanolog.ETLogEntry)}, u32(3), u32(MaxStringLen+1)),
+			lines:  1,
+			offset: 18,
+			msg:    "String of 1073741825 bytes is too long",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v, err := New(bytes.NewReader(test.input), &bytes.Buffer{}).Verify()
+
+			if v.Lines != test.lines || v.Entries != test.entries {
+				t.Fatalf("Expected %d lines and %d entries but got %d and %d", test.lines, test.entries, v.Lines, v.Entries)
+			}
+
+			if test.offset < 0 {
+				if err != nil {
+					t.Fatalf("Got error verifying a good log: %v", err)
+				}
+				if v.Size != int64(len(test.input)) {
+					t.Fatalf("Expected size %d but got %d", len(test.input), v.Size)
+				}
+				return
+			}
+
+			verr, ok := err.(*VerifyError)
+			if !ok {
+				t.Fatalf("Expected a *VerifyError but got %#v", err)
+			}
+			if verr.Offset != test.offset || v.Size != test.offset {
+				t.Fatalf("Expected the bad record at %d but got offset %d and size %d", test.offset, verr.Offset, v.Size)
+			}
+			if verr.Err.Error() != test.msg {
+				t.Fatalf("Expected error %q but got %q", test.msg, verr.Err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nanolog
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// WithFlightRecorder keeps the entries for log lines below the level in memory
+// instead of writing them, in a ring of size bytes that holds the most recent
+// ones. They are written to the log, oldest first, when an entry at the dumpAt
+// level or above is logged, just before that entry, or when
+// DumpFlightRecorder is called. That gives the detail leading up to a failure
+// without writing it out the rest of the time. The log lines themselves are
+// written as usual, so the dumped entries can be read like any others, but
+// they are out of time order with the entries around them. A size of 0 turns
+// the recorder off.
+func WithFlightRecorder(size int, below, dumpAt Level) Option {
+	return func(lw *logWriter) {
+		if size <= 4 {
+			lw.recorder = nil
+			return
+		}
+
+		lw.recorder = newRing(make([]byte, RingHeaderLen+size), 0, size)
+		lw.recordBelow = below
+		lw.dumpAt = dumpAt
+	}
+}
+
+// DumpFlightRecorder calls LogWriter.DumpFlightRecorder on the default log
+// writer.
+func DumpFlightRecorder() error {
+	return defaultLogWriter.DumpFlightRecorder()
+}
+
+// DumpFlightRecorder writes the entries in the flight recorder to the log and
+// flushes it. It does nothing without WithFlightRecorder.
+func (lw *logWriter) DumpFlightRecorder() error {
+	lw.writeLock.Lock()
+	defer lw.writeLock.Unlock()
+
+	if lw.closed {
+		return ErrClosed
+	}
+	if lw.recorder == nil {
+		return nil
+	}
+
+	if err := lw.dumpRecorder(); err != nil {
+		return err
+	}
+
+	return lw.flush()
+}
+
+// dumpRecorder writes the entries in the flight recorder to the log and
+// empties it. The write lock must be held.
+func (lw *logWriter) dumpRecorder() error {
+	var err error
+
+	lw.recorder.drain(func(rec []byte) {
+		if err == nil {
+			err = lw.write(rec)
+			lw.entries++
+		}
+	})
+
+	return err
+}
+
+// DumpOnSignal dumps the flight recorder of the log writer, or of the default
+// log writer if it is nil, each time the process gets one of the signals, e.g.
+// syscall.SIGUSR1. The returned function stops it.
+func DumpOnSignal(lw LogWriter, sigs ...os.Signal) (stop func()) {
+	if lw == nil {
+		lw = defaultLogWriter
+	}
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, sigs...)
+
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-c:
+				lw.DumpFlightRecorder()
+			case <-done:
+				signal.Stop(c)
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nanolog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// u8Entry is the record for an entry of a "%u8" log line
+func u8Entry(h Handle, v uint8) []byte {
+	rec := []byte{byte(ETLogEntry), 0, 0, 0, 0, v}
+	binary.LittleEndian.PutUint32(rec[1:], uint32(h))
+	return rec
+}
+
+func TestFlightRecorder(t *testing.T) {
+	buf := &bytes.Buffer{}
+	// room for the last 3 debug entries of 6 bytes and their lengths
+	lw := New(WithFlightRecorder(3*10, LevelInfo, LevelError))
+	lw.SetWriter(buf)
+
+	hDebug := lw.AddLeveledLogger(LevelDebug, "debug %u8")
+	hInfo := lw.AddLogger("info %u8")
+	hErr := lw.AddLeveledLogger(LevelError, "error %u8")
+	lw.Flush()
+	start := buf.Len()
+
+	for i := 0; i < 5; i++ {
+		lw.Log(hDebug, uint8(i))
+	}
+	lw.Log(hInfo, uint8(5))
+	lw.Flush()
+
+	if !bytes.Equal(buf.Bytes()[start:], u8Entry(hInfo, 5)) {
+		t.Fatalf("Expected only the info entry to be written but got % X", buf.Bytes()[start:])
+	}
+
+	lw.Log(hErr, uint8(6))
+	lw.Flush()
+
+	var expected []byte
+	expected = append(expected, u8Entry(hInfo, 5)...)
+	for i := 2; i < 5; i++ {
+		expected = append(expected, u8Entry(hDebug, uint8(i))...)
+	}
+	expected = append(expected, u8Entry(hErr, 6)...)
+
+	if !bytes.Equal(buf.Bytes()[start:], expected) {
+		t.Fatalf("Expected the last 3 debug entries before the error entry.\nExpected: % X\nGot: % X", expected, buf.Bytes()[start:])
+	}
+
+	// the recorder is empty after a dump
+	lw.Log(hErr, uint8(7))
+	lw.Flush()
+
+	expected = append(expected, u8Entry(hErr, 7)...)
+	if !bytes.Equal(buf.Bytes()[start:], expected) {
+		t.Fatalf("Expected nothing more to be dumped.\nExpected: % X\nGot: % X", expected, buf.Bytes()[start:])
+	}
+}
+
+func TestDumpFlightRecorder(t *testing.T) {
+	buf := &bytes.Buffer{}
+	lw := New(WithFlightRecorder(1024, LevelInfo, LevelError))
+	lw.SetWriter(buf)
+
+	h := lw.AddLeveledLogger(LevelDebug, "debug %u8")
+	lw.Flush()
+	start := buf.Len()
+
+	lw.Log(h, uint8(1))
+	if err := lw.DumpFlightRecorder(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !bytes.Equal(buf.Bytes()[start:], u8Entry(h, 1)) {
+		t.Fatalf("Expected the debug entry to be dumped and flushed but got % X", buf.Bytes()[start:])
+	}
+
+	lw.Close()
+	if err := lw.DumpFlightRecorder(); err != ErrClosed {
+		t.Fatalf("Expected ErrClosed but got %v", err)
+	}
+}
+
+func TestFlightRecorderOff(t *testing.T) {
+	buf := &bytes.Buffer{}
+	lw := New(WithFlightRecorder(0, LevelInfo, LevelError))
+	lw.SetWriter(buf)
+
+	h := lw.AddLeveledLogger(LevelDebug, "debug %u8")
+	lw.Flush()
+	start := buf.Len()
+
+	lw.Log(h, uint8(1))
+	lw.Flush()
+
+	if !bytes.Equal(buf.Bytes()[start:], u8Entry(h, 1)) {
+		t.Fatalf("Expected the debug entry to be written as usual but got % X", buf.Bytes()[start:])
+	}
+	if err := lw.DumpFlightRecorder(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestDumpOnSignal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Signals can't be sent to the process on windows")
+	}
+
+	buf := &lockedBuffer{}
+	lw := New(WithFlightRecorder(1024, LevelInfo, LevelError))
+	lw.SetWriter(buf)
+	h := lw.AddLeveledLogger(LevelDebug, "debug %u8")
+	lw.Flush()
+	start := buf.Len()
+
+	lw.Log(h, uint8(1))
+
+	// the handler in DumpOnSignal keeps the signal from killing the test
+	stop := DumpOnSignal(lw, syscall.SIGHUP)
+	defer stop()
+
+	// make sure the signal is seen here too so it is only sent once handled
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+	defer signal.Stop(c)
+
+	p, _ := os.FindProcess(os.Getpid())
+	if err := p.Signal(syscall.SIGHUP); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for buf.Len() == start {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the flight recorder to be dumped on the signal")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nanolog
+
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+)
+
+// number of log lines in each chunk of the registry
+const registryChunk = 64
+
+type loggerChunk [registryChunk]Logger
+
+// registry holds the log lines of a LogWriter. It grows a chunk at a time so
+// small programs don't pay for space they don't use, and it is read without
+// locking: a chunk never moves once it is allocated, and the table of chunks is
+// only ever replaced by a longer one, never changed where readers can see it.
+type registry struct {
+	// []*loggerChunk
+	chunks atomic.Value
+	// number of log lines added, read atomically
+	n uint32
+	// most log lines allowed, or 0 for no limit, read atomically since it can
+	// be changed by Configure
+	max uint32
+}
+
+// get returns the log line for the handle, or nil if it hasn't been added
+func (r *registry) get(h Handle) *Logger {
+	if uint32(h) >= atomic.LoadUint32(&r.n) {
+		return nil
+	}
+
+	chunks, _ := r.chunks.Load().([]*loggerChunk)
+
+	c := int(h / registryChunk)
+	if c >= len(chunks) {
+		return nil
+	}
+
+	return &chunks[c][h%registryChunk]
+}
+
+// len returns the number of log lines added
+func (r *registry) len() uint32 {
+	return atomic.LoadUint32(&r.n)
+}
+
+// add stores the log line under the next handle. Adds must be serialized by the
+// caller, gets may happen at the same time.
+func (r *registry) add(l Logger) Handle {
+	idx := atomic.LoadUint32(&r.n)
+
+	max := atomic.LoadUint32(&r.max)
+	if (max > 0 && idx >= max) || idx == math.MaxUint32 {
+		panic("Too many loggers")
+	}
+
+	chunks, _ := r.chunks.Load().([]*loggerChunk)
+	if int(idx/registryChunk) >= len(chunks) {
+		// append only writes past the end readers can see
+		chunks = append(chunks, new(loggerChunk))
+		r.chunks.Store(chunks)
+	}
+
+	chunks[idx/registryChunk][idx%registryChunk] = l
+
+	// publish the log line only once it is in place
+	atomic.StoreUint32(&r.n, idx+1)
+
+	return Handle(idx)
+}
+
+// WithMaxLoggers sets the most log lines that can be added, after which
+// AddLogger panics. It defaults to MaxLoggers; 0 means there is no limit. The
+// space for log lines is allocated as they are added, not up front.
+func WithMaxLoggers(n int) Option {
+	return func(lw *logWriter) {
+		if n < 0 || uint64(n) > math.MaxUint32 {
+			n = 0
+		}
+		atomic.StoreUint32(&lw.reg.max, uint32(n))
+	}
+}
+
+// WithDedupe makes AddLogger return the existing handle when a log line with
+// the same format has already been added, instead of using up another handle
+// and writing the log line again. Formats are compared after parsing, so
+// "%{i}" and "%i" are the same, but the same format at another level is a new
+// log line. The source location of the first log line is the one that is kept.
+func WithDedupe(enabled bool) Option {
+	return func(lw *logWriter) {
+		lw.dedupe = enabled
+	}
+}
+
+// AddNamedLogger calls LogWriter.AddNamedLogger on the default log writer.
+func AddNamedLogger(name, fmt string) Handle {
+	return defaultLogWriter.addLogger(name, LevelInfo, fmt)
+}
+
+// AddNamedLogger adds a log line like AddLogger and gives it a name that is
+// written to the log and can be used with LookupName. Adding the same name
+// with the same format again returns the existing handle; adding it with a
+// different format panics.
+func (lw *logWriter) AddNamedLogger(name, fmt string) Handle {
+	return lw.addLogger(name, LevelInfo, fmt)
+}
+
+// Lookup calls LogWriter.Lookup on the default log writer.
+func Lookup(fmt string) (Handle, bool) {
+	return defaultLogWriter.Lookup(fmt)
+}
+
+func (lw *logWriter) Lookup(fmt string) (Handle, bool) {
+	// normalize the format the same way as when it was added
+	f, ok := normalizeFormat(fmt)
+	if !ok {
+		return 0, false
+	}
+
+	lw.regLock.Lock()
+	defer lw.regLock.Unlock()
+
+	h, ok := lw.byFormat[f]
+	if !ok {
+		return 0, false
+	}
+
+	return lw.handle(h), true
+}
+
+// normalizeFormat returns the format of the log line parsed from fmt, or false
+// if fmt is malformed and couldn't have been added
+func normalizeFormat(fmt string) (f string, ok bool) {
+	defer func() {
+		if recover() != nil {
+			f, ok = "", false
+		}
+	}()
+
+	return parseLogLine(fmt).Format(), true
+}
+
+// LookupName calls LogWriter.LookupName on the default log writer.
+func LookupName(name string) (Handle, bool) {
+	return defaultLogWriter.LookupName(name)
+}
+
+func (lw *logWriter) LookupName(name string) (Handle, bool) {
+	lw.regLock.Lock()
+	defer lw.regLock.Unlock()
+
+	h, ok := lw.byName[name]
+	if !ok {
+		return 0, false
+	}
+
+	return lw.handle(h), true
+}
+
+// existing returns the handle to use instead of adding l, if there is one. The
+// registry lock must be held.
+func (lw *logWriter) existing(l Logger) (Handle, bool) {
+	if l.Name != "" {
+		h, ok := lw.byName[l.Name]
+		if !ok {
+			return 0, false
+		}
+
+		if f := lw.reg.get(h).Format(); f != l.Format() {
+			panic(fmt.Sprintf("Logger name %q was already added with the format %q", l.Name, f))
+		}
+
+		return h, true
+	}
+
+	if lw.dedupe {
+		// only reuse an unnamed log line, a named one has its own identity,
+		// and only at the same level
+		if h, ok := lw.byFormat[l.Format()]; ok {
+			if prev := lw.reg.get(h); prev.Name == "" && prev.Level == l.Level {
+				return h, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// register adds a new log line to the lookups. The registry lock must be held.
+func (lw *logWriter) register(h Handle, l Logger) {
+	f := l.Format()
+	if _, ok := lw.byFormat[f]; !ok {
+		lw.byFormat[f] = h
+	}
+
+	if l.Name != "" {
+		lw.byName[l.Name] = h
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nanolog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync"
+	"testing"
+)
+
+func TestDedupe(t *testing.T) {
+	buf := &bytes.Buffer{}
+	lw := New(WithDedupe(true))
+	lw.SetWriter(buf)
+
+	h1 := lw.AddLogger("dedupe %i")
+	h2 := lw.AddLogger("dedupe %{i}")
+	h3 := lw.AddLogger("other %i")
+	lw.Flush()
+
+	if h1 != h2 {
+		t.Fatalf("Expected the same handle for the same format but got %v and %v", h1, h2)
+	}
+	if h3 == h1 {
+		t.Fatalf("Expected a new handle for a different format")
+	}
+
+	expected := append(logLineRecord(0, parseLogLine("dedupe %i")), logLineRecord(1, parseLogLine("other %i"))...)
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Fatalf("Expected each log line to be written once.\nExpected: % X\nGot: % X", expected, buf.Bytes())
+	}
+}
+
+func TestDedupeOff(t *testing.T) {
+	lw := New()
+
+	if h1, h2 := lw.AddLogger("same"), lw.AddLogger("same"); h1 == h2 {
+		t.Fatalf("Expected different handles without dedupe but got %v twice", h1)
+	}
+}
+
+func TestDedupeConcurrent(t *testing.T) {
+	lw := New(WithDedupe(true))
+
+	handles := make([]Handle, 50)
+	wg := sync.WaitGroup{}
+
+	for i := range handles {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			handles[i] = lw.AddLogger("concurrent %s")
+		}(i)
+	}
+
+	wg.Wait()
+
+	for _, h := range handles {
+		if h != handles[0] {
+			t.Fatalf("Expected every goroutine to get the same handle but got %v", handles)
+		}
+	}
+	if n := len(lw.Schema()); n != 1 {
+		t.Fatalf("Expected 1 log line but got %d", n)
+	}
+}
+
+func TestAddNamedLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	lw := New(WithDedupe(true))
+	lw.SetWriter(buf)
+
+	unnamed := lw.AddLogger("request %s")
+	named := lw.AddNamedLogger("request", "request %s")
+	again := lw.AddNamedLogger("request", "request %s")
+	lw.Flush()
+
+	if named == unnamed {
+		t.Fatalf("Expected a named log line not to be deduped with an unnamed one")
+	}
+	if again != named {
+		t.Fatalf("Expected the same handle for the same name but got %v and %v", named, again)
+	}
+
+	l := parseLogLine("request %s")
+	l.Name = "request"
+	expected := append(logLineRecord(0, parseLogLine("request %s")), logLineRecord(1, l)...)
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Fatalf("Expected the name to be written.\nExpected: % X\nGot: % X", expected, buf.Bytes())
+	}
+
+	if h, ok := lw.LookupName("request"); !ok || h != named {
+		t.Fatalf("Expected to find %v by name but got %v, %v", named, h, ok)
+	}
+	if _, ok := lw.LookupName("missing"); ok {
+		t.Fatalf("Expected not to find a missing name")
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("Expected a panic for a name with a different format")
+		}
+	}()
+
+	lw.AddNamedLogger("request", "request %i")
+}
+
+func TestLookup(t *testing.T) {
+	lw := New()
+	h1 := lw.AddLogger("lookup %{i} done")
+	lw.AddLogger("lookup %i done")
+
+	if h, ok := lw.Lookup("lookup %i done"); !ok || h != h1 {
+		t.Fatalf("Expected to find the first log line %v but got %v, %v", h1, h, ok)
+	}
+	if _, ok := lw.Lookup("lookup %s done"); ok {
+		t.Fatalf("Expected not to find a missing format")
+	}
+
+	for _, f := range []string{"%z", "%{i", "lookup %{i done", "%{u8:q}"} {
+		if h, ok := lw.Lookup(f); ok || h != 0 {
+			t.Fatalf("Expected not to find the malformed format %q but got %v, %v", f, h, ok)
+		}
+	}
+}
+
+func TestMaxLoggers(t *testing.T) {
+	t.Run("Unlimited", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		lw := New(WithMaxLoggers(0))
+		lw.SetWriter(buf)
+
+		var h Handle
+		for i := 0; i < MaxLoggers+registryChunk+1; i++ {
+			h = lw.AddLogger("grow %u32")
+		}
+		lw.Flush()
+		buf.Reset()
+
+		if err := lw.Log(h, uint32(7)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		lw.Flush()
+
+		expected := []byte{byte(ETLogEntry), 0, 0, 0, 0, 7, 0, 0, 0}
+		binary.LittleEndian.PutUint32(expected[1:], uint32(h))
+		if !bytes.Equal(buf.Bytes(), expected) {
+			t.Fatalf("Expected % X but got % X", expected, buf.Bytes())
+		}
+		if n := len(lw.Schema()); n != MaxLoggers+registryChunk+1 {
+			t.Fatalf("Expected %d log lines but got %d", MaxLoggers+registryChunk+1, n)
+		}
+	})
+
+	t.Run("Limit", func(t *testing.T) {
+		lw := New(WithMaxLoggers(3))
+		for i := 0; i < 3; i++ {
+			lw.AddLogger("limited")
+		}
+
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("Expected a panic but did not get one")
+			}
+		}()
+
+		lw.AddLogger("limited")
+	})
+}
+
+func TestRegistryConcurrent(t *testing.T) {
+	lw := New(WithMaxLoggers(0))
+	lw.SetWriter(&bytes.Buffer{})
+	h := lw.AddLogger("first %i")
+
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+
+	// logging has to keep working while the registry grows under it
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10*registryChunk; i++ {
+			lw.AddLogger("more %i")
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10*registryChunk; i++ {
+			if err := lw.Log(h, i); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+}
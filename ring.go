@@ -0,0 +1,392 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nanolog
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// A ring file keeps the most recent entries of a log in a memory-mapped file,
+// so they survive the process being killed without a chance to flush, e.g. by
+// SIGKILL or the OOM killer. Every record is copied into the mapping as it is
+// logged and the kernel writes the pages out on its own. Nothing is lost to a
+// crash of the process, but a crash of the machine can lose anything that
+// wasn't synced.
+//
+// The log line records are kept in an area of their own so that the entries
+// that refer to them can always be decoded, and the entries go in a ring that
+// overwrites the oldest ones when it is full. The file is formatted as follows:
+//
+//  - magic:    8 bytes - RingMagic
+//  - version:  4 bytes - little endian uint32, 1
+//  - reserved: 4 bytes
+//  - lines:    8 bytes - little endian uint64 size of the log line area
+//  - ring:     8 bytes - little endian uint64 size of the entry ring
+//  - used:     8 bytes - little endian uint64 number of bytes used in the log line area
+//  - head:     8 bytes - little endian uint64 position after the newest entry
+//  - tail:     8 bytes - little endian uint64 position of the oldest entry
+//  - reserved: 8 bytes
+//  - the log line area, holding ETLogLine and ETDictionary records one after another
+//  - the entry ring, holding the other records each preceded by its length as a
+//    little endian uint32
+//
+// Positions in the ring count every byte ever written to it, so the offset in
+// the ring is the position modulo its size, and records wrap around its end.
+// Only complete records are ever between the tail and the head: the tail is
+// moved past the entries about to be overwritten before they are, and the
+// head is moved past a new entry after it is copied in. The used, head and
+// tail fields are each written with a single store so a kill can't tear them.
+//
+// The "nanolog recover" command turns a ring file back into a normal log.
+
+// RingMagic is the start of a ring file
+const RingMagic = "nanoring"
+
+// RingHeaderLen is the size of the header of a ring file
+const RingHeaderLen = 64
+
+const (
+	ringVersion = 1
+
+	ringOffLinesCap = 16
+	ringOffRingCap  = 24
+	ringOffUsed     = 32
+	ringOffHead     = 40
+	ringOffTail     = 48
+)
+
+var (
+	// ErrRingUnsupported is returned by OpenRing where memory-mapped files
+	// aren't supported
+	ErrRingUnsupported = errors.New("Ring files are only supported on linux")
+	// ErrRingLinesFull is returned when a log line doesn't fit in the log line
+	// area of a ring, and by Log for the entries of that log line
+	ErrRingLinesFull = errors.New("Ring log line area is full")
+	// ErrRingRecordTooLarge is returned for an entry larger than the ring
+	ErrRingRecordTooLarge = errors.New("Record is larger than the ring")
+	// ErrRingWriter is returned by SetWriter for a LogWriter that writes to a
+	// ring
+	ErrRingWriter = errors.New("LogWriter writes to a ring")
+)
+
+// Ring is a ring file opened with OpenRing. It is written by a LogWriter given
+// WithRing, one record per call to Write.
+type Ring struct {
+	mu sync.Mutex
+
+	data  []byte
+	lines []byte
+	ring  []byte
+
+	// number and size of the entries overwritten to make room for new ones
+	dropped      uint64
+	droppedBytes uint64
+
+	// releases the mapping and the file, if there are any
+	unmap func() error
+	// syncs the mapping to the file, if there is one
+	msync func() error
+}
+
+// OpenRing creates a ring file at path with room for linesSize bytes of log
+// line records and ringSize bytes of entries, and maps it into memory. If a
+// ring file is already at path it is moved to path + ".prev" first, so the
+// entries from a previous run that was killed can still be recovered.
+func OpenRing(path string, linesSize, ringSize int) (*Ring, error) {
+	if linesSize <= 0 || ringSize <= 4 {
+		return nil, errors.New("Ring sizes are too small")
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		if err := os.Rename(path, path+".prev"); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	size := RingHeaderLen + linesSize + ringSize
+	if err := f.Truncate(int64(size)); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	data, unmap, msync, err := mapFile(f, size)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	r := newRing(data, linesSize, ringSize)
+	r.unmap = unmap
+	r.msync = msync
+
+	return r, nil
+}
+
+// newRing lays out a ring in data, which must be RingHeaderLen + linesSize +
+// ringSize bytes
+func newRing(data []byte, linesSize, ringSize int) *Ring {
+	copy(data, RingMagic)
+	binary.LittleEndian.PutUint32(data[8:], ringVersion)
+	binary.LittleEndian.PutUint64(data[ringOffLinesCap:], uint64(linesSize))
+	binary.LittleEndian.PutUint64(data[ringOffRingCap:], uint64(ringSize))
+
+	return &Ring{
+		data:  data,
+		lines: data[RingHeaderLen : RingHeaderLen+linesSize],
+		ring:  data[RingHeaderLen+linesSize:],
+	}
+}
+
+// Write copies a single record into the ring file: a log line record into the
+// log line area and anything else into the entry ring
+func (r *Ring) Write(rec []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.data == nil {
+		return 0, ErrClosed
+	}
+	if len(rec) == 0 {
+		return 0, nil
+	}
+
+	if isLineRecord(rec) {
+		return r.writeLine(rec)
+	}
+	return r.writeEntry(rec)
+}
+
+// writeRing writes a record to the ring, keeping count of the entries it
+// overwrites. The write lock must be held.
+func (lw *logWriter) writeRing(b []byte) (int, error) {
+	r := lw.ring
+	dropped, droppedBytes := r.dropped, r.droppedBytes
+
+	n, err := r.Write(b)
+
+	lw.dropRingEntries(r.dropped-dropped, r.droppedBytes-droppedBytes)
+	return n, err
+}
+
+// reserveRing makes room in the ring for a record of n bytes before it is
+// written, so the counts can be taken with nothing left to drop. The write lock
+// must be held.
+func (lw *logWriter) reserveRing(n int) {
+	r := lw.ring
+	dropped, droppedBytes := r.dropped, r.droppedBytes
+
+	r.reserve(n)
+
+	lw.dropRingEntries(r.dropped-dropped, r.droppedBytes-droppedBytes)
+}
+
+// dropRingEntries counts entries overwritten in the ring. They stay in the
+// offset and the number of entries written, and are only left out of the
+// trailer. The write lock must be held.
+func (lw *logWriter) dropRingEntries(n, size uint64) {
+	lw.ringDropped += n
+	lw.ringDroppedBytes += int64(size)
+}
+
+// isLineRecord is whether a record describes log lines rather than being an
+// entry or anything else that comes after them
+func isLineRecord(rec []byte) bool {
+	t := EntryType(rec[0])
+	return t == ETLogLine || t == ETDictionary
+}
+
+func (r *Ring) writeLine(rec []byte) (int, error) {
+	used := r.load(ringOffUsed)
+	if used+uint64(len(rec)) > uint64(len(r.lines)) {
+		return 0, ErrRingLinesFull
+	}
+
+	copy(r.lines[used:], rec)
+	r.store(ringOffUsed, used+uint64(len(rec)))
+
+	return len(rec), nil
+}
+
+func (r *Ring) writeEntry(rec []byte) (int, error) {
+	size := uint64(len(r.ring))
+	need := uint64(4 + len(rec))
+	if need > size {
+		return 0, ErrRingRecordTooLarge
+	}
+
+	r.makeRoom(need)
+	head := r.load(ringOffHead)
+
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], uint32(len(rec)))
+	r.copyIn(head, b[:])
+	r.copyIn(head+4, rec)
+
+	r.store(ringOffHead, head+need)
+
+	return len(rec), nil
+}
+
+// makeRoom drops the oldest entries until need more bytes fit in the ring. The
+// lock must be held.
+func (r *Ring) makeRoom(need uint64) {
+	size := uint64(len(r.ring))
+	head, tail := r.load(ringOffHead), r.load(ringOffTail)
+	if head+need-tail <= size {
+		return
+	}
+
+	var b [4]byte
+	for head+need-tail > size {
+		r.copyOut(tail, b[:])
+		n := uint64(binary.LittleEndian.Uint32(b[:]))
+		tail += 4 + n
+		r.dropped++
+		r.droppedBytes += n
+	}
+	r.store(ringOffTail, tail)
+}
+
+// reserve drops the oldest entries to make room for an entry of n bytes, so
+// that writing it later doesn't drop any
+func (r *Ring) reserve(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.data != nil && uint64(4+n) <= uint64(len(r.ring)) {
+		r.makeRoom(uint64(4 + n))
+	}
+}
+
+// drain calls f with each entry in the ring, oldest first, and empties it. The
+// record passed to f is only valid during the call.
+func (r *Ring) drain(f func(rec []byte)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var buf []byte
+	var b [4]byte
+
+	head := r.load(ringOffHead)
+	for pos := r.load(ringOffTail); pos < head; {
+		r.copyOut(pos, b[:])
+		n := binary.LittleEndian.Uint32(b[:])
+
+		if uint32(cap(buf)) < n {
+			buf = make([]byte, n)
+		}
+		buf = buf[:n]
+		r.copyOut(pos+4, buf)
+
+		f(buf)
+		pos += 4 + uint64(n)
+	}
+
+	r.store(ringOffHead, 0)
+	r.store(ringOffTail, 0)
+}
+
+// copyIn copies b into the ring at the position, wrapping around its end
+func (r *Ring) copyIn(pos uint64, b []byte) {
+	n := copy(r.ring[pos%uint64(len(r.ring)):], b)
+	copy(r.ring, b[n:])
+}
+
+// copyOut copies from the ring at the position into b, wrapping around its end
+func (r *Ring) copyOut(pos uint64, b []byte) {
+	n := copy(b, r.ring[pos%uint64(len(r.ring)):])
+	copy(b[n:], r.ring)
+}
+
+// load and store access a header field as a single little endian uint64
+func (r *Ring) load(off int) uint64 {
+	return binary.LittleEndian.Uint64(r.data[off:])
+}
+
+func (r *Ring) store(off int, v uint64) {
+	var le [8]byte
+	binary.LittleEndian.PutUint64(le[:], v)
+	atomic.StoreUint64((*uint64)(unsafe.Pointer(&r.data[off])), *(*uint64)(unsafe.Pointer(&le[0])))
+}
+
+// Sync writes the mapped file out to storage
+func (r *Ring) Sync() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.data == nil {
+		return ErrClosed
+	}
+	if r.msync == nil {
+		return nil
+	}
+	return r.msync()
+}
+
+// Close unmaps and closes the ring file. The entries stay in the file.
+func (r *Ring) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.data == nil {
+		return ErrClosed
+	}
+
+	r.data, r.lines, r.ring = nil, nil, nil
+	if r.unmap == nil {
+		return nil
+	}
+	return r.unmap()
+}
+
+// WithRing writes the log to a ring file instead of a writer given to
+// SetWriter, copying each record into it as it is logged so there is nothing to
+// lose in a buffer. Log lines that were added before the option is applied are
+// copied in right away, so options that change the log line records, like
+// WithSourceLocations, only apply to them if they come before it. Close closes
+// the ring and WithSync syncs it. A trailer counts only the entries left in the
+// ring, and has no checksum since a recovered log has the log lines ahead of
+// all of the entries.
+func WithRing(r *Ring) Option {
+	return func(lw *logWriter) {
+		// anything buffered goes where it was meant to
+		lw.flush()
+
+		// the ring is a log of its own, starting with the log lines
+		lw.ring = r
+		lw.out = r
+		lw.sum = nil
+		lw.offset = 0
+		lw.lines = 0
+
+		if lw.dict != nil {
+			lw.writeDictionaryRef()
+		}
+
+		lw.eachLogger(func(idx uint32, l Logger) {
+			lw.writeLogLine(idx, lw.logLineRecord(idx, l))
+		})
+	}
+}
@@ -12,26 +12,13 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+//go:build !linux
+// +build !linux
 
-import (
-	"flag"
-	"os"
+package nanolog
 
-	"github.com/ScottMansfield/nanolog/reader"
-)
+import "os"
 
-func main() {
-	var fileName string
-	flag.StringVar(&fileName, "f", "", "Input file name")
-	flag.Parse()
-
-	infile, err := os.Open(fileName)
-	if err != nil {
-		panic(err)
-	}
-
-	if err := reader.New(infile, os.Stdout).Inflate(); err != nil {
-		panic(err)
-	}
+func mapFile(f *os.File, size int) (data []byte, unmap, msync func() error, err error) {
+	return nil, nil, nil, ErrRingUnsupported
 }
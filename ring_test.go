@@ -0,0 +1,201 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nanolog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// ringEntries returns the records between the tail and the head of the ring
+func ringEntries(r *Ring) [][]byte {
+	var recs [][]byte
+
+	head, tail := r.load(ringOffHead), r.load(ringOffTail)
+	for pos := tail; pos < head; {
+		var b [4]byte
+		r.copyOut(pos, b[:])
+		rec := make([]byte, binary.LittleEndian.Uint32(b[:]))
+		r.copyOut(pos+4, rec)
+		recs = append(recs, rec)
+		pos += 4 + uint64(len(rec))
+	}
+
+	return recs
+}
+
+func TestRing(t *testing.T) {
+	// room for 3 entries of 6 bytes and their lengths, plus a bit
+	const ringSize = 3*10 + 4
+	r := newRing(make([]byte, RingHeaderLen+64+ringSize), 64, ringSize)
+
+	lw := New(WithRing(r))
+	h := lw.AddLogger("ring %u8")
+
+	for i := 0; i < 10; i++ {
+		if err := lw.Log(h, uint8(i)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	line := logLineRecord(0, parseLogLine("ring %u8"))
+	if used := r.load(ringOffUsed); !bytes.Equal(r.lines[:used], line) {
+		t.Fatalf("Expected the log line in the log line area but got % X", r.lines[:used])
+	}
+
+	// the newest entries are kept, wrapping around the end of the ring
+	recs := ringEntries(r)
+	if len(recs) != 3 {
+		t.Fatalf("Expected 3 entries in the ring but got %d", len(recs))
+	}
+	for i, rec := range recs {
+		expected := []byte{byte(ETLogEntry), 0, 0, 0, 0, byte(7 + i)}
+		if !bytes.Equal(rec, expected) {
+			t.Fatalf("Expected entry %d to be % X but got % X", i, expected, rec)
+		}
+	}
+
+	if _, err := r.Write(make([]byte, ringSize)); err != ErrRingRecordTooLarge {
+		t.Fatalf("Expected ErrRingRecordTooLarge but got %v", err)
+	}
+	if _, err := r.Write(append([]byte{byte(ETLogLine)}, make([]byte, 64)...)); err != ErrRingLinesFull {
+		t.Fatalf("Expected ErrRingLinesFull but got %v", err)
+	}
+	if err := lw.SetWriter(ioutil.Discard); err != ErrRingWriter {
+		t.Fatalf("Expected ErrRingWriter but got %v", err)
+	}
+
+	if err := lw.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := r.Write([]byte{byte(ETLogEntry)}); err != ErrClosed {
+		t.Fatalf("Expected the ring to be closed but got %v", err)
+	}
+}
+
+func TestOpenRing(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nanolog")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "log.ring")
+
+	r, err := OpenRing(path, 1024, 1024)
+	if runtime.GOOS != "linux" {
+		if err != ErrRingUnsupported {
+			t.Fatalf("Expected ErrRingUnsupported but got %v", err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	lw := New(WithRing(r))
+	h := lw.AddLogger("mapped %s")
+	lw.Log(h, "entry")
+
+	// the entry is in the file without flushing or closing
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !bytes.Contains(data, []byte("entry")) || !bytes.HasPrefix(data, []byte(RingMagic)) {
+		t.Fatal("Expected the entry to be in the ring file")
+	}
+
+	if err := lw.Sync(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	lw.Close()
+
+	// a new ring keeps the old one for recovery
+	r, err = OpenRing(path, 1024, 1024)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	r.Close()
+
+	if prev, err := ioutil.ReadFile(path + ".prev"); err != nil || !bytes.Equal(prev, data) {
+		t.Fatalf("Expected the previous ring to be kept, got error %v", err)
+	}
+}
+
+func TestRingSyncEntries(t *testing.T) {
+	const ringSize = 3*10 + 4
+	r := newRing(make([]byte, RingHeaderLen+64+ringSize), 64, ringSize)
+
+	lw := New(WithRing(r), WithSync(SyncPolicy{Entries: 3}))
+	h := lw.AddLogger("ring %u8")
+
+	// overwriting entries in the ring doesn't hold back the syncs
+	for i := 0; i < 20; i++ {
+		lw.Log(h, uint8(i))
+	}
+
+	if s := lw.SyncStats(); s.Syncs != 6 {
+		t.Fatalf("Expected 6 syncs but got %d", s.Syncs)
+	}
+}
+
+func TestRingLinesFull(t *testing.T) {
+	// room for the first log line only
+	linesSize := len(logLineRecord(0, parseLogLine("ring %u8")))
+	r := newRing(make([]byte, RingHeaderLen+linesSize+64), linesSize, 64)
+
+	lw := New(WithRing(r))
+	h := lw.AddLogger("ring %u8")
+	full := lw.AddLogger("full %u8")
+
+	if err := lw.Log(h, uint8(1)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := lw.Log(full, uint8(2)); err != ErrRingLinesFull {
+		t.Fatalf("Expected ErrRingLinesFull but got %v", err)
+	}
+
+	recs := ringEntries(r)
+	if len(recs) != 1 || !bytes.Equal(recs[0], u8Entry(h, 1)) {
+		t.Fatalf("Expected only the entry for the log line in the ring but got % X", recs)
+	}
+}
+
+func TestRingOptionsAfter(t *testing.T) {
+	old := defaultLogWriter
+	defaultLogWriter = newLogWriter()
+	defer func() { defaultLogWriter = old }()
+
+	r := newRing(make([]byte, RingHeaderLen+1024+64), 1024, 64)
+
+	AddLogger("ring %u8")
+	Configure(WithRing(r))
+	used := r.load(ringOffUsed)
+
+	// the log lines already in the ring aren't written again
+	Configure(WithSourceLocations(true), WithTrailer(true))
+
+	if r.load(ringOffUsed) != used {
+		t.Fatalf("Expected %d bytes of log lines in the ring but got %d", used, r.load(ringOffUsed))
+	}
+	if defaultLogWriter.lines != 1 {
+		t.Fatalf("Expected 1 log line but got %d", defaultLogWriter.lines)
+	}
+}
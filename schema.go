@@ -0,0 +1,77 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nanolog
+
+// LineSchema describes a log line registered with AddLogger. It is what Schema
+// returns for the log lines of a LogWriter and what the reader package returns
+// for the log lines in a file, and it is meant to be exported as JSON.
+type LineSchema struct {
+	Handle Handle `json:"handle"`
+	// Name is the name given to AddNamedLogger, if any
+	Name string `json:"name,omitempty"`
+	// Level is the name of the level of the log line, e.g. "info"
+	Level string `json:"level"`
+	// Format is the format string of the log line, as given by Logger.Format
+	Format string `json:"format"`
+	// Segs are the string segments around the interpolations
+	Segs []string `json:"segs"`
+	// Kinds are the names of the kinds of the interpolations, e.g. "int32"
+	Kinds []string `json:"kinds"`
+	// Mods are the presentation modifiers of the interpolations, if any
+	Mods []string `json:"mods,omitempty"`
+	// File, Line and Func are where the log line was added, if known
+	File string `json:"file,omitempty"`
+	Line int    `json:"line,omitempty"`
+	Func string `json:"func,omitempty"`
+}
+
+// NewLineSchema describes the log line l registered as handle h
+func NewLineSchema(h Handle, l Logger) LineSchema {
+	ls := LineSchema{
+		Handle: h,
+		Name:   l.Name,
+		Level:  l.Level.String(),
+		Format: l.Format(),
+		Segs:   l.Segs,
+		Kinds:  make([]string, len(l.Kinds)),
+		Mods:   l.Mods,
+		File:   l.File,
+		Line:   l.Line,
+		Func:   l.Func,
+	}
+
+	for i, k := range l.Kinds {
+		ls.Kinds[i] = k.String()
+	}
+
+	return ls
+}
+
+// Schema calls LogWriter.Schema on the default log writer.
+func Schema() []LineSchema {
+	return defaultLogWriter.Schema()
+}
+
+func (lw *logWriter) Schema() []LineSchema {
+	lw.writeLock.Lock()
+	defer lw.writeLock.Unlock()
+
+	var schema []LineSchema
+	lw.eachLogger(func(idx uint32, l Logger) {
+		schema = append(schema, NewLineSchema(Handle(idx), l))
+	})
+
+	return schema
+}
@@ -0,0 +1,71 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nanolog
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestSchema(t *testing.T) {
+	lw := New()
+	_, file, line, _ := runtime.Caller(0)
+	h1 := lw.AddLogger("first %i32 and %{f64:.2}")
+	h2 := lw.AddLeveledLogger(LevelWarn, "second")
+	fn := "github.com/ScottMansfield/nanolog.TestSchema"
+
+	expected := []LineSchema{
+		{
+			Handle: h1,
+			Level:  "info",
+			Format: "first %i32 and %{f64:.2}",
+			Segs:   []string{"first ", " and ", ""},
+			Kinds:  []string{"int32", "float64"},
+			Mods:   []string{"", ".2"},
+			File:   file,
+			Line:   line + 1,
+			Func:   fn,
+		},
+		{
+			Handle: h2,
+			Level:  "warn",
+			Format: "second",
+			Segs:   []string{"second"},
+			Kinds:  []string{},
+			File:   file,
+			Line:   line + 2,
+			Func:   fn,
+		},
+	}
+
+	if schema := lw.Schema(); !reflect.DeepEqual(schema, expected) {
+		t.Fatalf("Unexpected schema.\nExpected: %+v\nGot: %+v", expected, schema)
+	}
+}
+
+func TestDebugDump(t *testing.T) {
+	lw := New()
+	_, file, line, _ := runtime.Caller(0)
+	h := lw.AddLogger("dump %{u32:x}")
+
+	expected := fmt.Sprintf(`{"handle":0,"level":"info","format":"dump %%{u32:x}","segs":["dump ",""],"kinds":["uint32"],"mods":["x"],`+
+		`"file":%q,"line":%d,"func":"github.com/ScottMansfield/nanolog.TestDebugDump"}`, file, line+1)
+
+	if dump := lw.DebugDump(h); dump != expected {
+		t.Fatalf("Unexpected dump.\nExpected: %s\nGot: %s", expected, dump)
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nanolog
+
+import "time"
+
+// Syncer is implemented by writers that can commit what was written to them to
+// stable storage, like *os.File
+type Syncer interface {
+	Sync() error
+}
+
+// SyncPolicy says when the log is synced to stable storage. Each sync flushes
+// the log first. Any of the parts can be combined; the zero policy never syncs
+// except when Sync is called.
+type SyncPolicy struct {
+	// Interval syncs the log in the background this often
+	Interval time.Duration
+	// Entries syncs the log after every so many entries, in the Log call that
+	// writes the last one
+	Entries uint64
+	// OnFlush syncs the log every time it is flushed, whatever flushes it
+	OnFlush bool
+}
+
+// SyncStats describes the syncs done so far, for monitoring how long the
+// storage takes
+type SyncStats struct {
+	// Syncs is the number of syncs, including the failed ones
+	Syncs uint64
+	// Errors is the number of failed syncs
+	Errors uint64
+	// Total, Max and Last are the time taken by all of the syncs, the slowest
+	// one and the latest one
+	Total time.Duration
+	Max   time.Duration
+	Last  time.Duration
+}
+
+// Mean is the average time taken by a sync
+func (s SyncStats) Mean() time.Duration {
+	if s.Syncs == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Syncs)
+}
+
+// WithSync syncs the log to stable storage according to the policy, if the
+// writer given to SetWriter is a Syncer; otherwise syncing does nothing. When
+// syncing is on, Close syncs the log before closing it. Syncs are done with the
+// write lock held, so Log calls wait for them.
+func WithSync(policy SyncPolicy) Option {
+	return func(lw *logWriter) {
+		lw.syncPolicy = policy
+
+		stopRunning(&lw.syncStop)
+
+		if policy.Interval > 0 && !lw.closed {
+			lw.syncStop = make(chan struct{})
+			go runEvery(time.NewTicker(policy.Interval), lw.syncStop, lw.Sync)
+		}
+	}
+}
+
+// Sync calls LogWriter.Sync on the default log writer.
+func Sync() error {
+	return defaultLogWriter.Sync()
+}
+
+func (lw *logWriter) Sync() error {
+	lw.writeLock.Lock()
+	defer lw.writeLock.Unlock()
+
+	if lw.closed {
+		return ErrClosed
+	}
+
+	return lw.sync()
+}
+
+// sync flushes the log and syncs it. The write lock must be held.
+func (lw *logWriter) sync() error {
+	if err := lw.flush(); err != nil || lw.syncPolicy.OnFlush {
+		// flush already synced
+		return err
+	}
+
+	return lw.syncOut()
+}
+
+// syncOut syncs the writer the log was flushed to. The write lock must be held.
+func (lw *logWriter) syncOut() error {
+	s, ok := lw.out.(Syncer)
+	if !ok {
+		return nil
+	}
+
+	start := time.Now()
+	err := s.Sync()
+	d := time.Since(start)
+
+	lw.syncStats.Syncs++
+	if err != nil {
+		lw.syncStats.Errors++
+	}
+	lw.syncStats.Total += d
+	lw.syncStats.Last = d
+	if d > lw.syncStats.Max {
+		lw.syncStats.Max = d
+	}
+
+	return err
+}
+
+// syncing is whether the policy syncs at all. The write lock must be held.
+func (lw *logWriter) syncing() bool {
+	return lw.syncPolicy != SyncPolicy{}
+}
+
+// GetSyncStats calls LogWriter.SyncStats on the default log writer.
+func GetSyncStats() SyncStats {
+	return defaultLogWriter.SyncStats()
+}
+
+func (lw *logWriter) SyncStats() SyncStats {
+	lw.writeLock.Lock()
+	defer lw.writeLock.Unlock()
+
+	return lw.syncStats
+}
@@ -0,0 +1,141 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nanolog
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer records how much had been written at each sync
+type syncBuffer struct {
+	sync.Mutex
+	buf   bytes.Buffer
+	syncs []int
+	err   error
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.Lock()
+	defer s.Unlock()
+	return s.buf.Write(p)
+}
+
+func (s *syncBuffer) Sync() error {
+	s.Lock()
+	defer s.Unlock()
+	s.syncs = append(s.syncs, s.buf.Len())
+	return s.err
+}
+
+func (s *syncBuffer) numSyncs() int {
+	s.Lock()
+	defer s.Unlock()
+	return len(s.syncs)
+}
+
+func TestSyncEntries(t *testing.T) {
+	out := &syncBuffer{}
+	lw := New(WithSync(SyncPolicy{Entries: 2}))
+	lw.SetWriter(out)
+
+	h := lw.AddLogger("sync %u8")
+	for i := 0; i < 5; i++ {
+		lw.Log(h, uint8(i))
+	}
+
+	if len(out.syncs) != 2 {
+		t.Fatalf("Expected 2 syncs but got %d", len(out.syncs))
+	}
+	// the 4th entry is flushed before the sync and the 5th is still buffered
+	if last := out.syncs[1]; last != out.buf.Len() {
+		t.Fatalf("Expected the sync after the 4th entry to cover the whole output, got %d of %d bytes", last, out.buf.Len())
+	}
+
+	lw.Close()
+	if len(out.syncs) != 3 || out.syncs[2] != out.buf.Len() {
+		t.Fatalf("Expected Close to sync everything but got syncs %v of %d bytes", out.syncs, out.buf.Len())
+	}
+
+	if s := lw.SyncStats(); s.Syncs != 3 || s.Errors != 0 || s.Max < s.Last || s.Total < s.Max {
+		t.Fatalf("Unexpected stats %+v", s)
+	}
+}
+
+func TestSyncOnFlush(t *testing.T) {
+	out := &syncBuffer{err: errors.New("disk on fire")}
+	lw := New(WithSync(SyncPolicy{OnFlush: true}))
+	lw.SetWriter(out)
+
+	h := lw.AddLogger("flushed")
+	lw.Log(h)
+
+	if err := lw.Flush(); err != out.err {
+		t.Fatalf("Expected the sync error from Flush but got %v", err)
+	}
+	if err := lw.Sync(); err != out.err {
+		t.Fatalf("Expected the sync error from Sync but got %v", err)
+	}
+
+	if s := lw.SyncStats(); s.Syncs != 2 || s.Errors != 2 {
+		t.Fatalf("Expected 2 failed syncs but got %+v", s)
+	}
+}
+
+func TestSyncInterval(t *testing.T) {
+	out := &syncBuffer{}
+	lw := New(WithSync(SyncPolicy{Interval: time.Millisecond}))
+	lw.SetWriter(out)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for out.numSyncs() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected a sync in the background")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	lw.Close()
+	if lw.(*logWriter).syncStop != nil {
+		t.Fatal("Expected Close to stop the background sync")
+	}
+}
+
+func TestSyncFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "nanolog")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.Remove(f.Name())
+
+	lw := New()
+	lw.SetWriter(f)
+	h := lw.AddLogger("synced %i")
+	lw.Log(h, 1)
+
+	if err := lw.Sync(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if s := lw.SyncStats(); s.Syncs != 1 {
+		t.Fatalf("Expected the *os.File to be synced but got %+v", s)
+	}
+
+	lw.Close()
+}
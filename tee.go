@@ -0,0 +1,190 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nanolog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+)
+
+// ErrTeeWriter is returned by SetWriter for a LogWriter made with NewTee
+var ErrTeeWriter = errors.New("LogWriter writes to sinks")
+
+// Sink is one of the outputs of a LogWriter made with NewTee
+type Sink struct {
+	// W gets the records of the log
+	W io.Writer
+	// Level leaves out the entries for log lines below it. The log line
+	// records go to every sink. The zero Level is LevelInfo, so a sink that
+	// gets every entry needs LevelDebug.
+	Level Level
+	// Live writes each record to W as soon as it is logged instead of
+	// buffering them until the log is flushed, and never splits a record
+	// across calls to Write. A writer that renders the entries as they come,
+	// like a reader.TextSink, needs it.
+	Live bool
+}
+
+// NewTee creates a LogWriter that writes the log to each of the sinks, with the
+// options applied like New. It can be used to keep the binary log in a file
+// while showing the same entries as text, e.g.:
+//
+//	lw := nanolog.NewTee([]nanolog.Sink{
+//		{W: f, Level: nanolog.LevelDebug},
+//		{W: reader.NewTextSink(os.Stderr), Level: nanolog.LevelWarn, Live: true},
+//	})
+//
+// There is nothing to buffer before the writer is set, so SetWriter returns
+// ErrTeeWriter. Close closes the writers of the sinks, and syncing syncs them.
+// A trailer and an index describe the whole log, so they only match sinks that
+// get every entry.
+func NewTee(sinks []Sink, opts ...Option) LogWriter {
+	lw := newLogWriter(opts...)
+
+	t := &tee{}
+	for _, s := range sinks {
+		ts := teeSink{Sink: s}
+		if !s.Live {
+			ts.w = bufio.NewWriter(s.W)
+		}
+		t.sinks = append(t.sinks, ts)
+	}
+
+	lw.writeLock.Lock()
+	defer lw.writeLock.Unlock()
+
+	// anything the options wrote goes to every sink, in one piece so a live
+	// sink only ever gets whole records
+	init := &bytes.Buffer{}
+	lw.w.Flush()
+	lw.w = bufio.NewWriter(init)
+	lw.writeInit()
+	lw.w.Flush()
+	if init.Len() > 0 {
+		t.Write(init.Bytes())
+	}
+
+	lw.firstSet = false
+	lw.tee = t
+	lw.out = t
+
+	return lw
+}
+
+// writeTee writes a record to the sinks that take it. The write lock must be
+// held.
+func (lw *logWriter) writeTee(b []byte) (int, error) {
+	var level Level
+	entry := false
+
+	switch EntryType(b[0]) {
+	case ETLogEntry, ETTimedLogEntry:
+		entry = true
+		if l := lw.reg.get(Handle(binary.LittleEndian.Uint32(b[1:]))); l != nil {
+			level = l.Level
+		}
+	}
+
+	var err error
+	for _, s := range lw.tee.sinks {
+		if entry && level < s.Level {
+			continue
+		}
+		if werr := s.write(b); werr != nil && err == nil {
+			err = werr
+		}
+	}
+
+	return len(b), err
+}
+
+// tee is the output of a LogWriter made with NewTee
+type tee struct {
+	sinks []teeSink
+}
+
+type teeSink struct {
+	Sink
+	// buffers W unless the sink is live
+	w *bufio.Writer
+}
+
+func (s teeSink) write(b []byte) error {
+	var err error
+	if s.w != nil {
+		_, err = s.w.Write(b)
+	} else {
+		_, err = s.W.Write(b)
+	}
+	return err
+}
+
+// Write writes b to every sink
+func (t *tee) Write(b []byte) (int, error) {
+	var err error
+	for _, s := range t.sinks {
+		if werr := s.write(b); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return len(b), err
+}
+
+// Flush flushes the sinks that are buffered
+func (t *tee) Flush() error {
+	var err error
+	for _, s := range t.sinks {
+		if s.w != nil {
+			if ferr := s.w.Flush(); ferr != nil && err == nil {
+				err = ferr
+			}
+		}
+	}
+	return err
+}
+
+// Sync syncs the writers of the sinks that are Syncers
+func (t *tee) Sync() error {
+	var err error
+	for _, s := range t.sinks {
+		if sy, ok := s.W.(Syncer); ok {
+			if serr := sy.Sync(); serr != nil && err == nil {
+				err = serr
+			}
+		}
+	}
+	return err
+}
+
+// Close closes the writers of the sinks that are io.Closers, leaving os.Stdout
+// and os.Stderr open
+func (t *tee) Close() error {
+	var err error
+	for _, s := range t.sinks {
+		if s.W == os.Stdout || s.W == os.Stderr {
+			continue
+		}
+		if c, ok := s.W.(io.Closer); ok {
+			if cerr := c.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}
+	}
+	return err
+}
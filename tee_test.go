@@ -0,0 +1,115 @@
+// Copyright 2017 Scott Mansfield
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nanolog
+
+import (
+	"bytes"
+	"testing"
+)
+
+// recordWriter keeps each call to Write separately
+type recordWriter struct {
+	writes [][]byte
+}
+
+func (r *recordWriter) Write(p []byte) (int, error) {
+	r.writes = append(r.writes, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func TestTee(t *testing.T) {
+	all := &bytes.Buffer{}
+	warn := &recordWriter{}
+	lw := NewTee([]Sink{
+		{W: all, Level: LevelDebug},
+		{W: warn, Level: LevelWarn, Live: true},
+	})
+
+	hDebug := lw.AddLeveledLogger(LevelDebug, "debug %u8")
+	hWarn := lw.AddLeveledLogger(LevelWarn, "warn %u8")
+
+	lw.Log(hDebug, uint8(1))
+	lw.Log(hWarn, uint8(2))
+
+	if all.Len() != 0 {
+		t.Fatal("Expected the binary sink to be buffered")
+	}
+	if len(warn.writes) != 3 {
+		t.Fatalf("Expected the live sink to get each record as it is written but got %d writes", len(warn.writes))
+	}
+
+	lw.Flush()
+
+	l := parseLogLine("debug %u8")
+	l.Level = LevelDebug
+	debugLine := logLineRecord(0, l)
+	l = parseLogLine("warn %u8")
+	l.Level = LevelWarn
+	warnLine := logLineRecord(1, l)
+
+	var expected []byte
+	expected = append(expected, debugLine...)
+	expected = append(expected, warnLine...)
+	expected = append(expected, u8Entry(hDebug, 1)...)
+	expected = append(expected, u8Entry(hWarn, 2)...)
+
+	if !bytes.Equal(all.Bytes(), expected) {
+		t.Fatalf("Expected the whole log in the binary sink.\nExpected: % X\nGot: % X", expected, all.Bytes())
+	}
+
+	for i, rec := range [][]byte{debugLine, warnLine, u8Entry(hWarn, 2)} {
+		if !bytes.Equal(warn.writes[i], rec) {
+			t.Fatalf("Expected write %d to the live sink to be % X but got % X", i, rec, warn.writes[i])
+		}
+	}
+
+	if err := lw.SetWriter(&bytes.Buffer{}); err != ErrTeeWriter {
+		t.Fatalf("Expected ErrTeeWriter but got %v", err)
+	}
+}
+
+func TestTeeTrailer(t *testing.T) {
+	a, b := &bytes.Buffer{}, &bytes.Buffer{}
+	lw := NewTee([]Sink{{W: a}, {W: b, Live: true}}, WithTrailer(true))
+
+	h := lw.AddLogger("tee %u8")
+	lw.Log(h, uint8(1))
+	lw.Close()
+
+	if !bytes.Equal(a.Bytes(), b.Bytes()) {
+		t.Fatalf("Expected both sinks to get the same log but got % X and % X", a.Bytes(), b.Bytes())
+	}
+	if n := a.Len(); n < TrailerLen || EntryType(a.Bytes()[n-TrailerLen]) != ETTrailer {
+		t.Fatalf("Expected the log to end with a trailer but got % X", a.Bytes())
+	}
+}
+
+func TestTeeClose(t *testing.T) {
+	closed := &closeBuffer{}
+	lw := NewTee([]Sink{{W: closed}, {W: &bytes.Buffer{}}})
+
+	h := lw.AddLogger("close %u8")
+	lw.Log(h, uint8(1))
+
+	if err := lw.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if closed.closed != 1 {
+		t.Fatal("Expected the sink's writer to be closed")
+	}
+	if closed.Len() == 0 {
+		t.Fatal("Expected the log to be flushed before closing")
+	}
+}